@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"dwight/internal/diff"
+	"dwight/internal/journal"
+	"dwight/internal/policy"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// =============================================================================
+// Change journal
+// =============================================================================
+
+// revertTarget names what a revert undoes.
+type revertTarget struct {
+	undo bool   // the most recent write
+	path string // one file; empty (and !undo) for the whole session
+}
+
+// sessionJournal returns the journal of this run, starting it on first use.
+func (m *model) sessionJournal() *journal.Journal {
+	if m.journal == nil {
+		m.journal = journal.New()
+	}
+	return m.journal
+}
+
+// conversationID saves the chat if needed so writes can point back to it.
+func (m *model) conversationID() string {
+	if m.currentConversation == nil && len(m.chatMessages) > 0 {
+		m.saveCurrentChat()
+	}
+	if m.currentConversation == nil {
+		return ""
+	}
+	return m.currentConversation.ID
+}
+
+// writeAccepted writes an accepted file through the session journal.
+func (m *model) writeAccepted(path, content string) error {
+	return m.sessionJournal().Write(path, content, m.conversationID())
+}
+
+// writePolicy is where accepted code and tools may write: inside the working
+// directory or its git root, minus the configured deny-list.
+func (m *model) writePolicy() policy.Policy {
+	return policy.New(m.currentDir, m.workContext.GitRoot, m.config.DenyList())
+}
+
+// toolWritePolicy is writePolicy narrowed to the working directory, the root
+// tools resolve their paths against.
+func (m *model) toolWritePolicy() policy.Policy {
+	return m.writePolicy().Within(m.currentDir)
+}
+
+// relPath shows path relative to the working directory when it is inside it.
+func (m *model) relPath(path string) string {
+	if rel, err := filepath.Rel(m.currentDir, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return path
+}
+
+// revert undoes the target. When a file was edited after Dwight wrote it, it
+// asks before discarding those edits.
+func (m model) revert(t revertTarget, force bool) (tea.Model, tea.Cmd) {
+	if m.journal == nil || m.journal.Pending() == 0 {
+		return m, showStatus("No changes to revert")
+	}
+	var msg string
+	var err error
+	var modified []string
+	switch {
+	case t.undo:
+		var path string
+		path, err = m.journal.Undo(force)
+		msg = "Reverted " + m.relPath(path)
+		if errors.Is(err, journal.ErrModified) {
+			modified = []string{path}
+		}
+	case t.path != "":
+		err = m.journal.RevertFile(t.path, force)
+		msg = "Reverted " + m.relPath(t.path)
+		if errors.Is(err, journal.ErrModified) {
+			modified = []string{t.path}
+		}
+	default:
+		reverted := 0
+		for _, f := range m.journal.Files() {
+			if f.Reverted {
+				continue
+			}
+			switch ferr := m.journal.RevertFile(f.Path, force); {
+			case errors.Is(ferr, journal.ErrModified):
+				modified = append(modified, f.Path)
+			case ferr != nil:
+				err = ferr
+			default:
+				reverted++
+			}
+		}
+		msg = fmt.Sprintf("Reverted %d file(s)", reverted)
+		if err == nil && len(modified) > 0 {
+			err = journal.ErrModified
+		}
+	}
+	m.fileCache = nil
+
+	if len(modified) > 0 && !force {
+		m.pendingRevert = t
+		m.confirmDialog = &ConfirmDialog{
+			Action:       ConfirmRevert,
+			Message:      m.revertConfirmMessage(modified),
+			PreviousView: m.viewMode,
+		}
+		m.viewMode = ViewConfirmDialog
+		return m, nil
+	}
+	if err != nil {
+		return m, showStatus(fmt.Sprintf("Failed to revert: %v", err))
+	}
+	return m, showStatus(msg)
+}
+
+func (m *model) revertConfirmMessage(paths []string) string {
+	msg := "These files changed on disk after Dwight wrote them:\n\n"
+	for _, p := range paths {
+		msg += "  " + m.relPath(p) + "\n"
+	}
+	return msg + "\nRevert anyway and discard those edits?"
+}
+
+// =============================================================================
+// Changeset
+// =============================================================================
+
+// actionableBlocks returns the indexes of the code blocks that name a file.
+func (m *model) actionableBlocks() []int {
+	var idx []int
+	for i, b := range m.codeBlocks {
+		if b.Path != "" {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// openChangeset lists every file of the review at once, selecting all that
+// can be written.
+func (m *model) openChangeset() {
+	m.changesetSelected = map[int]bool{}
+	for _, i := range m.actionableBlocks() {
+		b := m.codeBlocks[i]
+		m.changesetSelected[i] = b.Denied == nil && b.Err == nil
+	}
+	m.changesetCursor = 0
+	m.changesetScroll = 0
+	m.viewMode = ViewChangeset
+}
+
+// changesetPreviewHeight is how many diff lines fit under the file list.
+func (m *model) changesetPreviewHeight() int {
+	return max(5, m.safeHeight()-len(m.actionableBlocks())-10)
+}
+
+// blockChange reports whether block creates its file and how many lines it
+// adds and removes, from the hunks computed when it was resolved.
+func blockChange(b CodeBlock) (created bool, added, removed int) {
+	if b.NewFile {
+		return true, len(diff.Split(b.Content)), 0
+	}
+	added, removed = diff.Stats(b.Hunks)
+	return false, added, removed
+}
+
+// applyChangeset writes the selected files together: if one write fails,
+// none of them are changed.
+func (m model) applyChangeset() (tea.Model, tea.Cmd) {
+	files := map[string]string{}
+	for i, on := range m.changesetSelected {
+		if b := m.codeBlocks[i]; on && b.Denied == nil && b.Err == nil {
+			files[b.Target.Path] = b.Content + "\n"
+		}
+	}
+	if len(files) == 0 {
+		return m, showStatus("No files selected")
+	}
+	if err := m.sessionJournal().WriteAll(files, m.conversationID()); err != nil {
+		return m, showStatus(fmt.Sprintf("Failed to apply changeset: %v", err))
+	}
+	m.fileCache = nil
+	m.viewMode = ViewChat
+	m.changesetSelected = nil
+	for path := range files {
+		m.reviewWritten = append(m.reviewWritten, path)
+	}
+	sort.Strings(m.reviewWritten)
+	msg := fmt.Sprintf("Applied %d file(s) (/changes to revert)", len(files))
+	hooks := m.endReview()
+	if hooks != nil {
+		msg += ", running hooks"
+	}
+	return m, tea.Batch(showStatus(msg), hooks)
+}
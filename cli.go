@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"dwight/internal/provider"
+	"dwight/internal/storage"
+)
+
+// =============================================================================
+// Headless subcommands
+// =============================================================================
+
+// runSubcommand dispatches `dwight <command> ...`. handled is false when args
+// do not name a subcommand, so main falls through to the TUI.
+func runSubcommand(args []string) (code int, handled bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch args[0] {
+	case "ask":
+		return runAsk(args[1:]), true
+	case "conv", "conversations":
+		return runConv(args[1:]), true
+	case "serve":
+		return runServe(args[1:]), true
+	case "commit-msg":
+		return runCommitMsg(args[1:]), true
+	case "review":
+		return runReview(args[1:]), true
+	}
+	return 0, false
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments, returning the positionals in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// resolveProfile returns the named profile, or the current one when name is empty.
+func resolveProfile(mc storage.ModelConfig, name string) (storage.ModelProfile, error) {
+	if name == "" {
+		return mc.Current(), nil
+	}
+	idx := mc.Index(name)
+	if idx < 0 {
+		return storage.ModelProfile{}, fmt.Errorf("unknown profile %q (have: %s)", name, strings.Join(profileNames(mc), ", "))
+	}
+	return mc.Profiles[idx], nil
+}
+
+// readPipedStdin returns stdin when it is a pipe or redirected file. Terminals
+// and inherited sockets are ignored so `dwight ask` never blocks waiting on them.
+func readPipedStdin() (string, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil || (info.Mode()&os.ModeNamedPipe == 0 && !info.Mode().IsRegular()) {
+		return "", nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %v", err)
+	}
+	return string(data), nil
+}
+
+// stdoutIsTerminal reports whether stdout is a terminal rather than a pipe or file.
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// chatResult is a finished, non-interactive generation.
+type chatResult struct {
+	Content      string
+	Duration     time.Duration
+	PromptTokens int
+	TotalTokens  int
+}
+
+// streamChat runs req against profile, copying content to w as it arrives.
+func streamChat(ctx context.Context, profile storage.ModelProfile, req provider.ChatRequest, w io.Writer) (chatResult, error) {
+	var res chatResult
+	backend, err := provider.ForProfile(profile)
+	if err != nil {
+		return res, err
+	}
+	ch, err := backend.ChatStream(ctx, req)
+	if err != nil {
+		return res, err
+	}
+	var content strings.Builder
+	for chunk := range ch {
+		if chunk.Err != nil {
+			return res, chunk.Err
+		}
+		if chunk.Content != "" {
+			content.WriteString(chunk.Content)
+			io.WriteString(w, chunk.Content)
+		}
+		if chunk.Done {
+			res.Duration = chunk.Duration
+			res.PromptTokens = chunk.PromptTokens
+			res.TotalTokens = chunk.TotalTokens
+			break
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	res.Content = content.String()
+	return res, nil
+}
+
+// =============================================================================
+// dwight ask
+// =============================================================================
+
+type askOutput struct {
+	Profile          string `json:"profile"`
+	Provider         string `json:"provider"`
+	Model            string `json:"model"`
+	Response         string `json:"response"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	DurationMS       int64  `json:"duration_ms"`
+	ConversationID   string `json:"conversation_id,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+func runAsk(args []string) int {
+	fs := flag.NewFlagSet("ask", flag.ContinueOnError)
+	profileName := fs.String("profile", "", "model profile to use (default: current profile)")
+	asJSON := fs.Bool("json", false, "print one JSON object with the response and token stats")
+	save := fs.Bool("save", false, "save the exchange to the conversation library")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `usage: dwight ask [--profile NAME] [--json] [--save] "question"
+       cat file | dwight ask "question"`)
+		fs.PrintDefaults()
+	}
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	question := strings.TrimSpace(strings.Join(rest, " "))
+	piped, err := readPipedStdin()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dwight ask: %v\n", err)
+		return 1
+	}
+	if question == "" && strings.TrimSpace(piped) == "" {
+		fs.Usage()
+		return 2
+	}
+
+	currentDir, _ := os.Getwd()
+	settings := storage.LoadSettings()
+	profile, err := resolveProfile(storage.LoadModelConfig(), *profileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dwight ask: %v\n", err)
+		return 2
+	}
+
+	// Only the question expands @file references; piped input is sent verbatim.
+	prompt := question
+	content := resolveAtReferences(question, currentDir)
+	if piped != "" {
+		input := strings.TrimRight(piped, "\n")
+		if question == "" {
+			prompt, content = input, input
+		} else {
+			prompt = question + "\n\n" + input
+			content = content + "\n\n```\n" + input + "\n```"
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	req := provider.ChatRequest{
+		Model:       profile.Model,
+		Messages:    []provider.Message{{Role: "user", Content: content}},
+		System:      strings.TrimSpace(composeSystemPrompt(settings.MainPrompt, profile.SystemPrompt)),
+		Temperature: profile.Temperature,
+		Options:     provider.OptionsFor(profile),
+		Timeout:     time.Duration(settings.ChatTimeout) * time.Second,
+	}
+
+	out := askOutput{
+		Profile:  profile.Name,
+		Provider: storage.NormalizeProvider(profile.Provider),
+		Model:    profile.Model,
+	}
+	var w io.Writer = os.Stdout
+	if *asJSON {
+		w = io.Discard
+	}
+
+	started := time.Now()
+	res, err := streamChat(ctx, profile, req, w)
+	if err != nil {
+		if *asJSON {
+			out.Error = err.Error()
+			writeJSON(os.Stdout, out)
+		} else {
+			fmt.Fprintln(os.Stdout)
+			fmt.Fprintf(os.Stderr, "dwight ask: %v\n", err)
+		}
+		return 1
+	}
+	if !*asJSON && !strings.HasSuffix(res.Content, "\n") {
+		fmt.Fprintln(os.Stdout)
+	}
+
+	if *save {
+		id, err := saveExchange(profile, currentDir, prompt, res, started, "ask")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dwight ask: failed to save conversation: %v\n", err)
+			return 1
+		}
+		out.ConversationID = id
+		if !*asJSON {
+			fmt.Fprintf(os.Stderr, "saved conversation %s\n", id)
+		}
+	}
+
+	if *asJSON {
+		out.Response = res.Content
+		out.PromptTokens = res.PromptTokens
+		out.TotalTokens = res.TotalTokens
+		out.CompletionTokens = res.TotalTokens - res.PromptTokens
+		if out.CompletionTokens < 0 {
+			out.CompletionTokens = 0
+		}
+		out.DurationMS = res.Duration.Milliseconds()
+		writeJSON(os.Stdout, out)
+	}
+	return 0
+}
+
+// saveExchange stores a single prompt/reply pair as a conversation.
+func saveExchange(profile storage.ModelProfile, dir, prompt string, res chatResult, started time.Time, tags ...string) (string, error) {
+	wc := storage.DetectWorkContext(dir)
+	user := storage.ConvMessage{
+		ID: storage.NewMessageID(), Role: "user", Content: prompt, Timestamp: started,
+	}
+	reply := storage.ConvMessage{
+		ID: storage.NewMessageID(), ParentID: user.ID,
+		Role: "assistant", Content: res.Content, Timestamp: time.Now(),
+		Duration: res.Duration, PromptTokens: res.PromptTokens, TotalTokens: res.TotalTokens,
+	}
+	msgs := []storage.ConvMessage{user, reply}
+	title := storage.GenerateTitle(msgs)
+	if tags == nil {
+		tags = []string{}
+	}
+	conv := &storage.Conversation{
+		ID:          storage.NewConversationSlug(title),
+		Title:       title,
+		Model:       profile.Model,
+		ProfileName: profile.Name,
+		Created:     started,
+		Messages:    msgs,
+		Tags:        tags,
+		WorkingDir:  wc.WorkingDir,
+		GitRoot:     wc.GitRoot,
+		OriginHint:  wc.OriginHint,
+	}
+	if err := storage.SaveConversation(conv); err != nil {
+		return "", err
+	}
+	return conv.ID, nil
+}
+
+func writeJSON(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
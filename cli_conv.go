@@ -0,0 +1,373 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dwight/internal/storage"
+)
+
+// =============================================================================
+// dwight conv
+// =============================================================================
+
+const convUsage = `usage: dwight conv <command> [flags]
+
+commands:
+    list                   List conversations, newest first
+    show <id>              Print a conversation as Markdown (--json for raw)
+    export <id>            Write a Markdown/JSON export (--format, -o)
+    delete <id>...         Delete conversations
+    search <query>         Find conversations whose title or messages match
+
+filters (list, search):
+    --project NAME   repo/folder name or part of its path/origin ("." = current repo)
+    --model NAME     part of the model or profile name
+    --tag TAG        conversations carrying TAG
+    --since DATE     updated on/after DATE (YYYY-MM-DD, or 7d / 12h ago)
+    --until DATE     updated before the end of DATE
+    --limit N        show at most N results
+    --json           machine-readable output
+
+ids may be abbreviated to any unique prefix.`
+
+func runConv(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Println(convUsage)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	switch args[0] {
+	case "list", "ls":
+		return runConvList(args[1:], false)
+	case "search":
+		return runConvList(args[1:], true)
+	case "show":
+		return runConvShow(args[1:])
+	case "export":
+		return runConvExport(args[1:])
+	case "delete", "rm":
+		return runConvDelete(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "dwight conv: unknown command %q\n\n%s\n", args[0], convUsage)
+	return 2
+}
+
+func convFail(err error) int {
+	fmt.Fprintf(os.Stderr, "dwight conv: %v\n", err)
+	return 1
+}
+
+// convListItem is the JSON shape for list/search results.
+type convListItem struct {
+	storage.ConversationMeta
+	Project string         `json:"project,omitempty"`
+	Matches []convMatchHit `json:"matches,omitempty"`
+}
+
+type convMatchHit struct {
+	Role    string `json:"role"`
+	Snippet string `json:"snippet"`
+}
+
+func runConvList(args []string, search bool) int {
+	name := "list"
+	if search {
+		name = "search"
+	}
+	fs := flag.NewFlagSet("conv "+name, flag.ContinueOnError)
+	project := fs.String("project", "", "filter by project")
+	model := fs.String("model", "", "filter by model or profile")
+	tag := fs.String("tag", "", "filter by tag")
+	since := fs.String("since", "", "updated on/after date")
+	until := fs.String("until", "", "updated on/before date")
+	limit := fs.Int("limit", 0, "maximum results")
+	asJSON := fs.Bool("json", false, "JSON output")
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), convUsage) }
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	query := strings.TrimSpace(strings.Join(rest, " "))
+	if search && query == "" {
+		fmt.Fprintln(os.Stderr, "dwight conv search: missing query")
+		return 2
+	}
+
+	filter := storage.ConversationFilter{Model: *model, Tag: *tag}
+	if *project == "." {
+		cwd, _ := os.Getwd()
+		wc := storage.DetectWorkContext(cwd)
+		filter.Project = wc.GitRoot
+		if filter.Project == "" {
+			filter.Project = wc.WorkingDir
+		}
+	} else {
+		filter.Project = *project
+	}
+	if filter.Since, err = parseDateFlag(*since, false); err != nil {
+		return convFail(err)
+	}
+	if filter.Until, err = parseDateFlag(*until, true); err != nil {
+		return convFail(err)
+	}
+
+	convs, err := storage.ListConversations()
+	if err != nil {
+		return convFail(err)
+	}
+	var items []convListItem
+	for _, c := range convs {
+		if !filter.Match(c) {
+			continue
+		}
+		item := convListItem{ConversationMeta: c, Project: convProject(c)}
+		if search {
+			full, err := storage.LoadConversation(c.ID)
+			if err != nil {
+				continue
+			}
+			item.Matches = searchConversation(full, query)
+			if len(item.Matches) == 0 {
+				continue
+			}
+		}
+		items = append(items, item)
+		if *limit > 0 && len(items) >= *limit {
+			break
+		}
+	}
+
+	if *asJSON {
+		if items == nil {
+			items = []convListItem{}
+		}
+		writeJSON(os.Stdout, items)
+		return 0
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(os.Stderr, "no conversations found")
+		return 0
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUPDATED\tPROJECT\tMODEL\tMSGS\tTITLE")
+	for _, it := range items {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			it.ID, it.LastModified.Format("2006-01-02 15:04"), orDash(it.Project),
+			orDash(it.Model), it.MessageCount, truncateStr(it.Title, 60))
+		if search {
+			fmt.Fprintf(tw, "\t\t\t\t\t%s: %s\n", it.Matches[0].Role, it.Matches[0].Snippet)
+		}
+	}
+	tw.Flush()
+	return 0
+}
+
+func runConvShow(args []string) int {
+	fs := flag.NewFlagSet("conv show", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the stored JSON")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(rest) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: dwight conv show [--json] <id>")
+		return 2
+	}
+	conv, err := loadConversationByPrefix(rest[0])
+	if err != nil {
+		return convFail(err)
+	}
+	if *asJSON {
+		writeJSON(os.Stdout, conv)
+		return 0
+	}
+	fmt.Print(storage.ExportMarkdown(conv))
+	return 0
+}
+
+func runConvExport(args []string) int {
+	fs := flag.NewFlagSet("conv export", flag.ContinueOnError)
+	format := fs.String("format", "md", "md or json")
+	output := fs.String("o", "", "output file ('-' for stdout; default: the exports directory)")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(rest) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: dwight conv export [--format md|json] [-o FILE] <id>")
+		return 2
+	}
+	conv, err := loadConversationByPrefix(rest[0])
+	if err != nil {
+		return convFail(err)
+	}
+
+	var content, ext string
+	switch strings.ToLower(*format) {
+	case "md", "markdown":
+		content, ext = storage.ExportMarkdown(conv), ".md"
+	case "json":
+		if content, err = storage.ExportJSON(conv); err != nil {
+			return convFail(err)
+		}
+		ext = ".json"
+	default:
+		fmt.Fprintf(os.Stderr, "dwight conv export: unsupported format %q\n", *format)
+		return 2
+	}
+
+	path := *output
+	switch path {
+	case "-":
+		io.WriteString(os.Stdout, content)
+		return 0
+	case "":
+		dir := exportDirForConversation(conv)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return convFail(fmt.Errorf("failed to create export dir: %v", err))
+		}
+		path = exportFilename(dir, conv.Created, ext)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return convFail(err)
+	}
+	fmt.Println(path)
+	return 0
+}
+
+func runConvDelete(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: dwight conv delete <id>...")
+		return 2
+	}
+	code := 0
+	for _, arg := range args {
+		conv, err := loadConversationByPrefix(arg)
+		if err == nil {
+			err = storage.DeleteConversation(conv.ID)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dwight conv: %s: %v\n", arg, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("deleted %s\n", conv.ID)
+	}
+	return code
+}
+
+// loadConversationByPrefix loads the conversation whose id is, or uniquely
+// starts with, prefix.
+func loadConversationByPrefix(prefix string) (*storage.Conversation, error) {
+	if conv, err := storage.LoadConversation(prefix); err == nil {
+		return conv, nil
+	}
+	convs, err := storage.ListConversations()
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, c := range convs {
+		if strings.HasPrefix(c.ID, prefix) {
+			matches = append(matches, c.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no conversation matches %q", prefix)
+	case 1:
+		return storage.LoadConversation(matches[0])
+	}
+	return nil, fmt.Errorf("%q is ambiguous: %s", prefix, strings.Join(matches, ", "))
+}
+
+// searchConversation returns one snippet per message (any branch) containing query.
+func searchConversation(conv *storage.Conversation, query string) []convMatchHit {
+	q := strings.ToLower(query)
+	var hits []convMatchHit
+	if strings.Contains(strings.ToLower(conv.Title), q) {
+		hits = append(hits, convMatchHit{Role: "title", Snippet: conv.Title})
+	}
+	for _, msg := range conv.AllMessages() {
+		if i := strings.Index(strings.ToLower(msg.Content), q); i >= 0 {
+			hits = append(hits, convMatchHit{Role: msg.Role, Snippet: snippetAround(msg.Content, i, len(query))})
+		}
+	}
+	return hits
+}
+
+func snippetAround(text string, idx, n int) string {
+	start := idx - 40
+	if start < 0 {
+		start = 0
+	}
+	end := idx + n + 40
+	if end > len(text) {
+		end = len(text)
+	}
+	s := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 {
+		s = "..." + s
+	}
+	if end < len(text) {
+		s += "..."
+	}
+	return s
+}
+
+// parseDateFlag accepts YYYY-MM-DD, RFC 3339, or a relative age such as 7d or
+// 12h. With endOfDay, a bare date means the end of that day.
+func parseDateFlag(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD, 7d or 12h)", value)
+}
+
+func convProject(c storage.ConversationMeta) string {
+	return storage.ContextLabel(storage.WorkContext{WorkingDir: c.WorkingDir, GitRoot: c.GitRoot, OriginHint: c.OriginHint})
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
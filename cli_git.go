@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dwight/internal/budget"
+	"dwight/internal/git"
+	"dwight/internal/provider"
+	"dwight/internal/storage"
+)
+
+// =============================================================================
+// dwight commit-msg / dwight review
+// =============================================================================
+
+// conventionalCommitPrompt asks for a Conventional Commits message.
+const conventionalCommitPrompt = `You write git commit messages in the Conventional Commits format. Reply with the message only: a summary line "type(scope): description" of at most 72 characters, where type is one of feat, fix, refactor, perf, docs, test, build, ci, chore or style and the scope is optional, then a blank line and a short body saying what changed and why. Do not wrap the message in code fences or quotes.`
+
+// reviewPrompt asks for findings grouped by file.
+const reviewPrompt = `You are a careful code reviewer. Review the git diff for bugs, security problems, missing error handling, performance and readability. Only report problems in changed lines. Reply in exactly this format and nothing else:
+
+## path/to/file
+- [high] L42: what is wrong and how to fix it
+- [low] L10: ...
+
+Severity is high (bugs, security, data loss), medium (likely problems, missing error handling) or low (style, naming, small improvements). Leave out files without findings. If there are no findings at all, reply "No findings."`
+
+// reviewJSONPrompt asks for the same findings as a JSON array.
+const reviewJSONPrompt = `You are a careful code reviewer. Review the git diff for bugs, security problems, missing error handling, performance and readability. Only report problems in changed lines. Reply with a JSON array only, without prose or code fences:
+
+[{"file": "path/to/file", "line": 42, "severity": "high", "message": "what is wrong and how to fix it"}]
+
+Severity is high (bugs, security, data loss), medium (likely problems, missing error handling) or low (style, naming, small improvements). Use 0 for line when it does not apply. Reply [] when there are no findings.`
+
+// commitHookScript is installed as prepare-commit-msg by --install-hook.
+const commitHookScript = `#!/bin/sh
+# Installed by dwight commit-msg --install-hook. Fills in the commit message
+# from the staged diff; a failure never blocks the commit.
+command -v dwight >/dev/null 2>&1 || exit 0
+dwight commit-msg --hook%s "$@" || true
+`
+
+// gitFlags are shared by commit-msg and review.
+type gitFlags struct {
+	profile *string
+	rng     *string
+	context *int
+}
+
+func addGitFlags(fs *flag.FlagSet) gitFlags {
+	return gitFlags{
+		profile: fs.String("profile", "", "model profile to use (default: current profile)"),
+		rng:     fs.String("range", "", "use the diff of a ref or range (e.g. main..HEAD) instead of the staged changes"),
+		context: fs.Int("context", 0, "context window in tokens; larger diffs are split (default: ask the provider)"),
+	}
+}
+
+// load finds the repository, reads the diff and prepares the profile.
+func (f gitFlags) load(ctx context.Context) (root, diffText string, d diffModel, err error) {
+	dir, _ := os.Getwd()
+	root = storage.DetectWorkContext(dir).GitRoot
+	if root == "" {
+		return "", "", d, fmt.Errorf("not inside a git repository")
+	}
+	if *f.rng != "" {
+		diffText, err = git.DiffRange(root, *f.rng)
+	} else {
+		diffText, err = git.StagedDiff(root)
+	}
+	if err != nil {
+		return root, "", d, err
+	}
+	if strings.TrimSpace(diffText) == "" {
+		if *f.rng != "" {
+			return root, "", d, fmt.Errorf("no changes in %s", *f.rng)
+		}
+		return root, "", d, fmt.Errorf("nothing staged (git add first, or pass --range)")
+	}
+
+	profile, err := resolveProfile(storage.LoadModelConfig(), *f.profile)
+	if err != nil {
+		return root, diffText, d, err
+	}
+	size := *f.context
+	if size <= 0 {
+		if backend, err := provider.ForProfile(profile); err == nil {
+			size = backend.ContextWindow(ctx, profile.Model, provider.OptionsFor(profile))
+		}
+	}
+	d = diffModel{
+		profile: profile,
+		timeout: time.Duration(storage.LoadSettings().ChatTimeout) * time.Second,
+		limit:   budget.Limit(size),
+	}
+	return root, diffText, d, nil
+}
+
+func runCommitMsg(args []string) int {
+	fs := flag.NewFlagSet("commit-msg", flag.ContinueOnError)
+	flags := addGitFlags(fs)
+	hook := fs.Bool("hook", false, "run as a prepare-commit-msg hook: MSGFILE [SOURCE [SHA]]")
+	install := fs.Bool("install-hook", false, "install dwight as this repository's prepare-commit-msg hook")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `usage: dwight commit-msg [--profile NAME] [--range REV] [--context TOKENS]
+       dwight commit-msg --install-hook [--profile NAME]
+       dwight commit-msg --hook MSGFILE [SOURCE [SHA]]
+
+Writes a Conventional Commits message for the staged changes to stdout.`)
+		fs.PrintDefaults()
+	}
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *install {
+		return installCommitHook(*flags.profile)
+	}
+	if *hook {
+		if len(rest) == 0 {
+			fs.Usage()
+			return 2
+		}
+		return runCommitHook(flags, rest)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	_, diffText, d, err := flags.load(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dwight commit-msg: %v\n", err)
+		return 1
+	}
+	// A terminal sees the reply as it streams. A pipe gets only the cleaned
+	// message without stray code fences, since it usually feeds git commit.
+	if stdoutIsTerminal() {
+		_, err := writeCommitMessage(ctx, d, conventionalCommitPrompt, diffText, "", os.Stdout)
+		fmt.Println()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dwight commit-msg: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	msg, err := writeCommitMessage(ctx, d, conventionalCommitPrompt, diffText, "", io.Discard)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dwight commit-msg: %v\n", err)
+		return 1
+	}
+	fmt.Println(msg)
+	return 0
+}
+
+// runCommitHook fills in the message file git passes to prepare-commit-msg.
+// Messages given with -m, merges, squashes and amends are left alone, and
+// errors are only reported so the commit still goes ahead.
+func runCommitHook(flags gitFlags, args []string) int {
+	msgFile := args[0]
+	if len(args) > 1 && args[1] != "" && args[1] != "template" {
+		return 0
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	_, diffText, d, err := flags.load(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dwight: no commit message written: %v\n", err)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "dwight: writing a commit message with %s...\n", d.profile.Name)
+	msg, err := writeCommitMessage(ctx, d, conventionalCommitPrompt, diffText, "", io.Discard)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dwight: no commit message written: %v\n", err)
+		return 0
+	}
+	// Keep git's commented template below the message.
+	existing, _ := os.ReadFile(msgFile)
+	if err := os.WriteFile(msgFile, []byte(msg+"\n"+string(existing)), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "dwight: failed to write %s: %v\n", msgFile, err)
+	}
+	return 0
+}
+
+func installCommitHook(profile string) int {
+	dir, _ := os.Getwd()
+	root := storage.DetectWorkContext(dir).GitRoot
+	if root == "" {
+		fmt.Fprintln(os.Stderr, "dwight commit-msg: not inside a git repository")
+		return 1
+	}
+	hooks, err := git.HooksDir(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dwight commit-msg: %v\n", err)
+		return 1
+	}
+	path := filepath.Join(hooks, "prepare-commit-msg")
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "dwight commit-msg: %s already exists; add `dwight commit-msg --hook \"$@\"` to it yourself\n", path)
+		return 1
+	}
+	args := ""
+	if profile != "" {
+		args = " --profile '" + strings.ReplaceAll(profile, "'", `'\''`) + "'"
+	}
+	if err := os.MkdirAll(hooks, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "dwight commit-msg: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(commitHookScript, args)), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "dwight commit-msg: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Installed %s\n", path)
+	return 0
+}
+
+// reviewFinding is one problem reported by `dwight review --json`.
+type reviewFinding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+type reviewOutput struct {
+	Profile  string          `json:"profile"`
+	Model    string          `json:"model"`
+	Range    string          `json:"range,omitempty"`
+	Parts    int             `json:"parts"`
+	Findings []reviewFinding `json:"findings"`
+	Error    string          `json:"error,omitempty"`
+}
+
+var severityRank = map[string]int{"high": 0, "medium": 1, "low": 2}
+
+func runReview(args []string) int {
+	fs := flag.NewFlagSet("review", flag.ContinueOnError)
+	flags := addGitFlags(fs)
+	asJSON := fs.Bool("json", false, "print the findings as one JSON object")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `usage: dwight review [--profile NAME] [--range REV] [--context TOKENS] [--json]
+
+Reviews the staged changes (or --range) and prints findings per file, each
+marked high, medium or low.`)
+		fs.PrintDefaults()
+	}
+	if _, err := parseInterspersed(fs, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	_, diffText, d, err := flags.load(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dwight review: %v\n", err)
+		return 1
+	}
+
+	system := reviewPrompt
+	if *asJSON {
+		system = reviewJSONPrompt
+	}
+	// Each chunk is reviewed on its own; findings do not need the whole diff.
+	chunks := git.Chunks(diffText, d.room(system, ""))
+	out := reviewOutput{Profile: d.profile.Name, Model: d.profile.Model, Range: *flags.rng, Parts: len(chunks), Findings: []reviewFinding{}}
+	for i, chunk := range chunks {
+		if len(chunks) > 1 {
+			fmt.Fprintf(os.Stderr, "dwight: reviewing part %d of %d\n", i+1, len(chunks))
+		}
+		user := "```diff\n" + strings.TrimRight(chunk, "\n") + "\n```"
+		if !*asJSON {
+			reply, err := d.ask(ctx, system, user, os.Stdout)
+			if err != nil {
+				fmt.Fprintln(os.Stdout)
+				fmt.Fprintf(os.Stderr, "dwight review: %v\n", err)
+				return 1
+			}
+			if !strings.HasSuffix(reply, "\n") {
+				fmt.Fprintln(os.Stdout)
+			}
+			continue
+		}
+		reply, err := d.ask(ctx, system, user, io.Discard)
+		if err == nil {
+			var found []reviewFinding
+			found, err = parseFindings(reply)
+			out.Findings = append(out.Findings, found...)
+		}
+		if err != nil {
+			out.Error = fmt.Sprintf("part %d of %d: %v", i+1, len(chunks), err)
+			writeJSON(os.Stdout, out)
+			return 1
+		}
+	}
+	if *asJSON {
+		sort.SliceStable(out.Findings, func(a, b int) bool {
+			return severityRank[out.Findings[a].Severity] < severityRank[out.Findings[b].Severity]
+		})
+		writeJSON(os.Stdout, out)
+	}
+	return 0
+}
+
+// parseFindings reads the JSON array a review reply should be, tolerating
+// prose or code fences around it.
+func parseFindings(reply string) ([]reviewFinding, error) {
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the model did not return a JSON array")
+	}
+	var found []reviewFinding
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &found); err != nil {
+		return nil, fmt.Errorf("the model returned invalid JSON: %v", err)
+	}
+	for i := range found {
+		sev := strings.ToLower(strings.TrimSpace(found[i].Severity))
+		if _, ok := severityRank[sev]; !ok {
+			sev = "low"
+		}
+		found[i].Severity = sev
+	}
+	return found, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"dwight/internal/server"
+	"dwight/internal/storage"
+)
+
+// =============================================================================
+// dwight serve
+// =============================================================================
+
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8088", "listen address")
+	logConvs := fs.Bool("log", false, "save every completion to the conversation library (tagged \"serve\")")
+	quiet := fs.Bool("quiet", false, "do not print a line per request")
+	token := fs.String("token", os.Getenv("DWIGHT_SERVE_TOKEN"), "require \"Authorization: Bearer TOKEN\" (default $DWIGHT_SERVE_TOKEN); needed off loopback")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `usage: dwight serve [--addr HOST:PORT] [--token TOKEN] [--log] [--quiet]
+
+Serves saved model profiles over an OpenAI-compatible API:
+    GET  /v1/models             one model per profile (id = profile name)
+    POST /v1/chat/completions   routed through the profile's provider`)
+		fs.PrintDefaults()
+	}
+	if _, err := parseInterspersed(fs, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	logger := log.New(os.Stderr, "dwight serve: ", log.LstdFlags)
+	cwd, _ := os.Getwd()
+	opts := server.Options{
+		LogConversations: *logConvs,
+		WorkContext:      storage.DetectWorkContext(cwd),
+		Token:            strings.TrimSpace(*token),
+	}
+	if !*quiet {
+		opts.Logf = logger.Printf
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dwight serve: %v\n", err)
+		return 1
+	}
+	if tcp, ok := ln.Addr().(*net.TCPAddr); ok && !tcp.IP.IsLoopback() && opts.Token == "" {
+		ln.Close()
+		fmt.Fprintf(os.Stderr, "dwight serve: %s is reachable beyond this machine; set --token or DWIGHT_SERVE_TOKEN\n", ln.Addr())
+		return 2
+	}
+	srv := &http.Server{Handler: server.Handler(opts), ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	mc := storage.LoadModelConfig()
+	logger.Printf("listening on http://%s/v1 (%d profiles)", ln.Addr(), len(mc.Profiles))
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "dwight serve: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dwight/internal/provider"
+	"dwight/internal/storage"
+	"dwight/internal/tools"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// =============================================================================
+// Slash commands
+// =============================================================================
+
+// slashCommand is a composer command such as "/temp 0.2". New commands only
+// need a registerCommand call in init below.
+type slashCommand struct {
+	Name  string
+	Usage string // argument hint shown in autocomplete
+	Help  string
+	Run   func(m *model, args string) tea.Cmd
+}
+
+var slashCommands = map[string]slashCommand{}
+
+func registerCommand(c slashCommand) {
+	slashCommands[c.Name] = c
+}
+
+// commandNames returns registered command names, sorted.
+func commandNames() []string {
+	names := make([]string, 0, len(slashCommands))
+	for name := range slashCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseSlashCommand splits "/name args" from a draft. A leading "//" is an
+// escaped slash, not a command.
+func parseSlashCommand(input string) (name, args string, ok bool) {
+	if !strings.HasPrefix(input, "/") || strings.HasPrefix(input, "//") {
+		return "", "", false
+	}
+	name, args, _ = strings.Cut(input[1:], " ")
+	return strings.ToLower(name), strings.TrimSpace(args), true
+}
+
+// runSlashCommand executes a parsed command against the chat. The composer is
+// cleared only for a known command, so a mistyped one can be corrected.
+func (m *model) runSlashCommand(name, args string) tea.Cmd {
+	cmd, ok := slashCommands[name]
+	if !ok {
+		return showStatus(fmt.Sprintf("Unknown command /%s (start with // to send a literal slash)", name))
+	}
+	m.chatTextArea.Reset()
+	m.syncChatLayout()
+	return cmd.Run(m, args)
+}
+
+func init() {
+	registerCommand(slashCommand{
+		Name: "model", Usage: "<profile>", Help: "Switch model profile",
+		Run: func(m *model, args string) tea.Cmd {
+			if args == "" {
+				return showStatus("Profiles: " + strings.Join(profileNames(m.modelConfig), ", "))
+			}
+			idx := m.modelConfig.Index(args)
+			if n, err := strconv.Atoi(args); idx < 0 && err == nil && n >= 1 && n <= len(m.modelConfig.Profiles) {
+				idx = n - 1
+			}
+			if idx < 0 {
+				return showStatus(fmt.Sprintf("No profile named '%s'", args))
+			}
+			m.modelConfig.CurrentProfile = idx
+			m.chatProfile = ""
+			storage.SaveModelConfig(m.modelConfig)
+			m.chatState = ChatStateCheckingModel
+			m.updateChatLines()
+			return tea.Batch(m.checkModel(), m.chatSpinner.Tick)
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "system", Usage: "[text]", Help: "Replace the profile system prompt for this chat",
+		Run: func(m *model, args string) tea.Cmd {
+			m.chatSystemPrompt = args
+			if args == "" {
+				return showStatus("System prompt reset to profile default")
+			}
+			return showStatus("System prompt set for this chat")
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "temp", Usage: "[0.0-2.0]", Help: "Override temperature for this chat",
+		Run: func(m *model, args string) tea.Cmd {
+			if args == "" {
+				m.chatTemperature = nil
+				return showStatus(fmt.Sprintf("Temperature reset to profile default (%.2g)", m.currentProfile().Temperature))
+			}
+			t, err := strconv.ParseFloat(args, 64)
+			if err != nil || t < 0 || t > 2 {
+				return showStatus("Temperature must be a number between 0 and 2")
+			}
+			m.chatTemperature = &t
+			return showStatus(fmt.Sprintf("Temperature set to %.2g", t))
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "save", Usage: "[title]", Help: "Save the conversation, optionally renaming it",
+		Run: func(m *model, args string) tea.Cmd {
+			if len(m.chatMessages) == 0 {
+				return showStatus("Nothing to save yet")
+			}
+			if err := m.saveCurrentChat(); err != nil {
+				return showStatus(fmt.Sprintf("Failed to save: %v", err))
+			}
+			if args != "" {
+				m.currentConversation.Title = args
+				if err := storage.SaveConversation(m.currentConversation); err != nil {
+					return showStatus(fmt.Sprintf("Failed to save: %v", err))
+				}
+				return showStatus(fmt.Sprintf("Saved as \"%s\"", args))
+			}
+			return showStatus("Conversation saved")
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "export", Usage: "md|json", Help: "Export the conversation",
+		Run: func(m *model, args string) tea.Cmd {
+			if len(m.chatMessages) == 0 {
+				return showStatus("Nothing to export yet")
+			}
+			switch strings.ToLower(args) {
+			case "", "md", "markdown":
+				return m.exportActiveConversation("markdown")
+			case "json":
+				return m.exportActiveConversation("json")
+			}
+			return showStatus("Usage: /export md|json")
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "attach", Usage: "<glob>", Help: "Attach matching files as context",
+		Run: func(m *model, args string) tea.Cmd {
+			if args == "" {
+				return showStatus("Usage: /attach <glob>")
+			}
+			added := 0
+			for _, pattern := range strings.Fields(args) {
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(m.currentDir, pattern)
+				}
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					return showStatus(fmt.Sprintf("Bad pattern: %v", err))
+				}
+				for _, path := range matches {
+					if info, err := os.Stat(path); err != nil || info.IsDir() || containsString(m.attachedResources, path) {
+						continue
+					}
+					m.attachedResources = append(m.attachedResources, path)
+					added++
+				}
+			}
+			if added == 0 {
+				return showStatus("No new files matched")
+			}
+			return showStatus(fmt.Sprintf("Attached %d file(s), %d total", added, len(m.attachedResources)))
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "clear", Help: "Clear the chat",
+		Run: func(m *model, args string) tea.Cmd {
+			return func() tea.Msg { return ClearChatMsg{} }
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "tag", Usage: "[tags...]", Help: "Tag the conversation (no args lists tags)",
+		Run: func(m *model, args string) tea.Cmd {
+			if args == "" {
+				if m.currentConversation == nil || len(m.currentConversation.Tags) == 0 {
+					return showStatus("No tags")
+				}
+				return showStatus("Tags: " + strings.Join(m.currentConversation.Tags, ", "))
+			}
+			if len(m.chatMessages) == 0 {
+				return showStatus("Send a message before tagging")
+			}
+			if err := m.saveCurrentChat(); err != nil {
+				return showStatus(fmt.Sprintf("Failed to save: %v", err))
+			}
+			conv := m.currentConversation
+			for _, tag := range strings.Fields(args) {
+				tag = strings.TrimPrefix(tag, "#")
+				if tag != "" && !containsString(conv.Tags, tag) {
+					conv.Tags = append(conv.Tags, tag)
+				}
+			}
+			if err := storage.SaveConversation(conv); err != nil {
+				return showStatus(fmt.Sprintf("Failed to save: %v", err))
+			}
+			return showStatus("Tags: " + strings.Join(conv.Tags, ", "))
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "tools", Usage: "[on|off]", Help: "Let the model read and write project files",
+		Run: func(m *model, args string) tea.Cmd {
+			switch strings.ToLower(args) {
+			case "":
+				m.settings.Tools = !m.settings.Tools
+			case "on":
+				m.settings.Tools = true
+			case "off":
+				m.settings.Tools = false
+			default:
+				return showStatus("Usage: /tools [on|off]")
+			}
+			storage.SaveSettings(m.settings)
+			if !m.settings.Tools {
+				return showStatus("Tools off")
+			}
+			if backend, err := provider.ForProfile(m.currentProfile()); err == nil && !backend.Capabilities().Tools {
+				return showStatus(fmt.Sprintf("Tools on (not supported by %s profiles)", backend.Name()))
+			}
+			return showStatus("Tools on: " + strings.Join(toolNames(), ", "))
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "undo", Help: "Revert the last file written",
+		Run: func(m *model, args string) tea.Cmd {
+			next, cmd := m.revert(revertTarget{undo: true}, false)
+			*m = next.(model)
+			return cmd
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "changes", Help: "List files written this session and revert them",
+		Run: func(m *model, args string) tea.Cmd {
+			m.changesCursor = 0
+			m.viewMode = ViewChanges
+			return nil
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "git", Help: "Diff, stage and commit the files written this session",
+		Run: func(m *model, args string) tea.Cmd {
+			return m.openGit()
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "hooks", Usage: "[run]", Help: "List post-apply hooks, or run them on this session's files",
+		Run: func(m *model, args string) tea.Cmd {
+			switch strings.ToLower(args) {
+			case "":
+				hooks := m.config.HooksFor(m.projectRoot())
+				if len(hooks) == 0 {
+					return showStatus("No post-apply hooks (add \"hooks\" to config.json)")
+				}
+				var list []string
+				for _, h := range hooks {
+					if h.Match != "" {
+						list = append(list, fmt.Sprintf("%s (%s)", h.Command, h.Match))
+					} else {
+						list = append(list, h.Command)
+					}
+				}
+				return showStatus("Hooks: " + strings.Join(list, " · "))
+			case "run":
+				if m.hooksRunning {
+					return showStatus("Hooks are already running")
+				}
+				var paths []string
+				if m.journal != nil {
+					for _, f := range m.journal.Files() {
+						if !f.Reverted {
+							paths = append(paths, f.Path)
+						}
+					}
+				}
+				if cmd := m.runPostApplyHooks(paths); cmd != nil {
+					return tea.Batch(showStatus("Running hooks"), cmd)
+				}
+				return showStatus("No hooks match the files written this session")
+			}
+			return showStatus("Usage: /hooks [run]")
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "edits", Usage: "[on|off]", Help: "Ask for SEARCH/REPLACE edit blocks instead of whole files",
+		Run: func(m *model, args string) tea.Cmd {
+			switch strings.ToLower(args) {
+			case "":
+				m.settings.EditBlocks = !m.settings.EditBlocks
+			case "on":
+				m.settings.EditBlocks = true
+			case "off":
+				m.settings.EditBlocks = false
+			default:
+				return showStatus("Usage: /edits [on|off]")
+			}
+			storage.SaveSettings(m.settings)
+			if m.settings.EditBlocks {
+				return showStatus("Edit blocks on: the model is asked for SEARCH/REPLACE edits")
+			}
+			return showStatus("Edit blocks off")
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "allow", Usage: "[add|rm <command>]", Help: "Commands run_command may run without asking in this project",
+		Run: func(m *model, args string) tea.Cmd {
+			root := m.projectRoot()
+			p := m.config.Project(root)
+			action, command, _ := strings.Cut(args, " ")
+			command = strings.TrimSpace(command)
+			switch {
+			case args == "":
+				if len(p.AllowedCommands) == 0 {
+					return showStatus("No auto-approved commands in this project")
+				}
+				return showStatus("Auto-approved: " + strings.Join(p.AllowedCommands, " · "))
+			case action == "add" && command != "":
+				m.allowCommand(command)
+				return showStatus("Auto-approved here: " + command)
+			case action == "rm" && command != "":
+				var kept []string
+				for _, c := range p.AllowedCommands {
+					if c != command {
+						kept = append(kept, c)
+					}
+				}
+				if len(kept) == len(p.AllowedCommands) {
+					return showStatus(fmt.Sprintf("'%s' is not in the allow-list", command))
+				}
+				p.AllowedCommands = kept
+				m.config.SetProject(root, p)
+				storage.SaveConfig(m.config)
+				return showStatus("Removed: " + command)
+			}
+			return showStatus("Usage: /allow [add|rm <command>] (end with * to match a prefix)")
+		},
+	})
+
+	registerCommand(slashCommand{
+		Name: "retry", Help: "Regenerate the last reply",
+		Run: func(m *model, args string) tea.Cmd {
+			next, cmd := m.regenerateReply()
+			*m = next.(model)
+			return cmd
+		},
+	})
+}
+
+func toolNames() []string {
+	var names []string
+	for _, t := range tools.All() {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func profileNames(cfg storage.ModelConfig) []string {
+	names := make([]string, len(cfg.Profiles))
+	for i, p := range cfg.Profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dwight/internal/budget"
+	"dwight/internal/git"
+	"dwight/internal/provider"
+	"dwight/internal/storage"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// =============================================================================
+// Git — status, stage and commit the files written this session
+// =============================================================================
+
+// conversationTrailer links a commit back to the conversation that wrote it.
+const conversationTrailer = "Dwight-Conversation"
+
+// commitMessagePrompt is the system prompt for writing commit messages.
+const commitMessagePrompt = `You write git commit messages. Reply with the message only: a summary line of at most 72 characters in the imperative mood, then a blank line and a short body saying what changed and why. Do not wrap the message in code fences or quotes.`
+
+type commitMessageMsg struct {
+	id      int
+	message string
+	err     error
+}
+
+// gitActionMsg reports a finished stage or commit.
+type gitActionMsg struct {
+	action string
+	hash   string
+	failed string // "stage" or "commit" when err is set
+	err    error
+}
+
+func newCommitMessageArea() textarea.Model {
+	ta := textarea.New()
+	ta.Placeholder = "Commit message (g to generate one from the diff)"
+	ta.CharLimit = 4000
+	ta.SetWidth(80)
+	ta.SetHeight(6)
+	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	ta.ShowLineNumbers = false
+	return ta
+}
+
+// sessionGitFiles lists the files written this session that are still
+// changed, relative to the git root.
+func (m *model) sessionGitFiles() []string {
+	if m.journal == nil || m.workContext.GitRoot == "" {
+		return nil
+	}
+	var files []string
+	for _, f := range m.journal.Files() {
+		if f.Reverted {
+			continue
+		}
+		if rel, err := filepath.Rel(m.workContext.GitRoot, f.Path); err == nil && filepath.IsLocal(rel) {
+			files = append(files, rel)
+		}
+	}
+	return files
+}
+
+// openGit shows the git view for this session's files.
+func (m *model) openGit() tea.Cmd {
+	if m.workContext.GitRoot == "" {
+		return showStatus("Cannot use git: not inside a git repository")
+	}
+	m.gitScroll = 0
+	m.gitEditing = false
+	m.gitMessage.SetWidth(max(20, m.width-6))
+	m.viewMode = ViewGit
+	if err := m.refreshGit(); err != nil {
+		return showStatus(fmt.Sprintf("Failed to read git status: %v", err))
+	}
+	return nil
+}
+
+// refreshGit reloads status, diff and the staged files Dwight did not write.
+func (m *model) refreshGit() error {
+	root := m.workContext.GitRoot
+	m.gitBranch = git.Branch(root)
+	m.gitFiles = m.sessionGitFiles()
+	m.gitStatus, m.gitDiff, m.gitUnrelated = "", "", nil
+
+	staged, err := git.Staged(root)
+	if err != nil {
+		return err
+	}
+	for _, p := range staged {
+		if !containsString(m.gitFiles, filepath.FromSlash(p)) {
+			m.gitUnrelated = append(m.gitUnrelated, p)
+		}
+	}
+	if len(m.gitFiles) == 0 {
+		return nil
+	}
+	if m.gitStatus, err = git.Status(root, m.gitFiles); err != nil {
+		return err
+	}
+	m.gitDiff, err = git.Diff(root, m.gitFiles)
+	m.gitScroll = min(m.gitScroll, max(0, len(diffLines(m.gitDiff))-m.gitDiffHeight()))
+	return err
+}
+
+// gitDiffHeight is how many diff lines fit between the status and the message.
+func (m *model) gitDiffHeight() int {
+	used := strings.Count(m.gitStatus, "\n") + m.gitMessage.Height() + 14
+	if len(m.gitUnrelated) > 0 {
+		used += 2
+	}
+	return max(5, m.safeHeight()-used)
+}
+
+func diffLines(text string) []string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// gitAction stages, or stages and commits, the session's files. Staged
+// changes Dwight did not write would end up in the commit, so it asks first.
+func (m model) gitAction(action string, confirmed bool) (tea.Model, tea.Cmd) {
+	if m.gitRunning != "" {
+		return m, nil
+	}
+	if len(m.gitFiles) == 0 {
+		return m, showStatus("No files written this session")
+	}
+	message := strings.TrimSpace(m.gitMessage.Value())
+	if action == "commit" && message == "" {
+		return m, showStatus("Write (e) or generate (g) a commit message first")
+	}
+	if len(m.gitUnrelated) > 0 && !confirmed {
+		m.pendingGitAction = action
+		m.confirmDialog = &ConfirmDialog{
+			Action:       ConfirmGitUnrelated,
+			Message:      gitUnrelatedMessage(m.gitUnrelated, action),
+			PreviousView: m.viewMode,
+		}
+		m.viewMode = ViewConfirmDialog
+		return m, nil
+	}
+
+	root := m.workContext.GitRoot
+	files := m.gitFiles
+	message = git.WithTrailer(message, conversationTrailer, m.conversationID())
+	m.gitRunning = action
+	return m, func() tea.Msg {
+		if err := git.Add(root, files); err != nil {
+			return gitActionMsg{action: action, failed: "stage", err: err}
+		}
+		if action == "stage" {
+			return gitActionMsg{action: action}
+		}
+		hash, err := git.Commit(root, message)
+		return gitActionMsg{action: action, hash: hash, failed: "commit", err: err}
+	}
+}
+
+// finishGitAction refreshes the view once a stage or commit is done.
+func (m model) finishGitAction(msg gitActionMsg) (tea.Model, tea.Cmd) {
+	m.gitRunning = ""
+	refreshErr := m.refreshGit()
+	if msg.err != nil {
+		return m, showStatus(fmt.Sprintf("Failed to %s: %v", msg.failed, msg.err))
+	}
+	status := fmt.Sprintf("Staged %d file(s)", len(m.gitFiles))
+	if msg.action == "commit" {
+		m.gitMessage.Reset()
+		m.gitEditing = false
+		m.gitMessage.Blur()
+		status = "Committed " + msg.hash
+	}
+	if refreshErr != nil {
+		status += fmt.Sprintf(", but failed to refresh: %v", refreshErr)
+	}
+	return m, showStatus(status)
+}
+
+func gitUnrelatedMessage(paths []string, action string) string {
+	msg := "These files are staged but were not written by Dwight:\n\n"
+	for i, p := range paths {
+		if i == 10 {
+			msg += fmt.Sprintf("  ... and %d more\n", len(paths)-i)
+			break
+		}
+		msg += "  " + p + "\n"
+	}
+	if action == "commit" {
+		return msg + "\nThe commit will include them too. Commit anyway?"
+	}
+	return msg + "\nA commit would include them too. Stage Dwight's files anyway?"
+}
+
+// generateCommitMessage asks the current profile for a message describing
+// the diff, with the conversation as background. Leaving the view cancels it.
+func (m *model) generateCommitMessage() tea.Cmd {
+	d := diffModel{
+		profile: m.currentProfile(),
+		timeout: time.Duration(m.settings.ChatTimeout) * time.Second,
+		limit:   budget.Limit(m.chatContextSize),
+	}
+	diffText := m.gitDiff
+	conversation := m.commitConversation()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.gitCancel = cancel
+	m.gitMessageID++
+	id := m.gitMessageID
+	return func() tea.Msg {
+		defer cancel()
+		msg, err := writeCommitMessage(ctx, d, commitMessagePrompt, diffText, conversation, io.Discard)
+		return commitMessageMsg{id: id, message: msg, err: err}
+	}
+}
+
+// commitConversation condenses the chat into the requests and replies that
+// explain why the files changed.
+func (m *model) commitConversation() string {
+	var b strings.Builder
+	for _, msg := range m.chatMessages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			continue
+		}
+		text := strings.TrimSpace(msg.Content)
+		if text == "" {
+			continue
+		}
+		if len(text) > 600 {
+			text = text[:600] + " [...]"
+		}
+		speaker := "User"
+		if msg.Role == "assistant" {
+			speaker = "Assistant"
+		}
+		fmt.Fprintf(&b, "%s: %s\n\n", speaker, text)
+	}
+	text := b.String()
+	// Keep the end of long chats: the latest requests matter most.
+	if len(text) > 6000 {
+		text = "[...]\n" + text[len(text)-6000:]
+	}
+	return strings.TrimSpace(text)
+}
+
+// writeCommitMessage has d write a commit message for diffText following
+// system, copying the reply to w as it streams. conversation, when set,
+// explains why the code changed.
+func writeCommitMessage(ctx context.Context, d diffModel, system, diffText, conversation string, w io.Writer) (string, error) {
+	if conversation != "" {
+		conversation = "Conversation that led to these changes:\n" + conversation + "\n\n"
+	}
+	body, err := d.fit(ctx, diffText, system, conversation)
+	if err != nil {
+		return "", err
+	}
+	out, err := d.ask(ctx, system, conversation+body, w)
+	if err != nil {
+		return "", err
+	}
+	msg := cleanCommitMessage(out)
+	if msg == "" {
+		return "", fmt.Errorf("the model returned an empty message")
+	}
+	return msg, nil
+}
+
+// cleanCommitMessage drops code fences and surrounding blank lines models
+// tend to add.
+func cleanCommitMessage(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "```") {
+		lines = lines[1:]
+	}
+	if len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[len(lines)-1]), "```") {
+		lines = lines[:len(lines)-1]
+	}
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// =============================================================================
+// Diffs too large for one request
+// =============================================================================
+
+// diffSummaryPrompt condenses one chunk of a diff that is too large to send whole.
+const diffSummaryPrompt = `You summarize one part of a larger git diff so the whole change can be described from the summaries. For every file in this part, list in terse bullet points what changed. Reply with the summary only.`
+
+// diffModel sends diffs to a profile, splitting those that do not fit its
+// context window.
+type diffModel struct {
+	profile storage.ModelProfile
+	timeout time.Duration
+	limit   int // prompt budget in tokens; 0 when the window is unknown
+}
+
+// room is how many bytes of diff fit in one request beside system and
+// extra, or 0 when there is no limit.
+func (d diffModel) room(system, extra string) int {
+	if d.limit <= 0 {
+		return 0
+	}
+	return max(1024, (d.limit-budget.EstimateTokens(system)-budget.EstimateTokens(extra)-256)*4)
+}
+
+// ask runs one request, copying the reply to w as it streams.
+func (d diffModel) ask(ctx context.Context, system, user string, w io.Writer) (string, error) {
+	req := provider.ChatRequest{
+		Model:       d.profile.Model,
+		Messages:    []provider.Message{{Role: "user", Content: user}},
+		System:      system,
+		Temperature: 0.2,
+		Options:     provider.OptionsFor(d.profile),
+		Timeout:     d.timeout,
+	}
+	res, err := streamChat(ctx, d.profile, req, w)
+	return res.Content, err
+}
+
+// fit returns diffText ready to send beside system and extra. A diff that
+// does not fit is summarized chunk by chunk and the summaries are sent instead.
+func (d diffModel) fit(ctx context.Context, diffText, system, extra string) (string, error) {
+	room := d.room(system, extra)
+	if room == 0 || len(diffText) <= room {
+		return "Diff:\n```diff\n" + strings.TrimRight(diffText, "\n") + "\n```", nil
+	}
+	chunks := git.Chunks(diffText, d.room(diffSummaryPrompt, ""))
+	var b strings.Builder
+	b.WriteString("The diff is too large to show whole. Summaries of its parts:\n")
+	for i, chunk := range chunks {
+		summary, err := d.ask(ctx, diffSummaryPrompt, "```diff\n"+strings.TrimRight(chunk, "\n")+"\n```", io.Discard)
+		if err != nil {
+			return "", fmt.Errorf("failed to summarize part %d of %d: %v", i+1, len(chunks), err)
+		}
+		fmt.Fprintf(&b, "\nPart %d of %d:\n%s\n", i+1, len(chunks), strings.TrimSpace(summary))
+	}
+	text := b.String()
+	if len(text) > room {
+		text = text[:room] + "\n[...]"
+	}
+	return text, nil
+}
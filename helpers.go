@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"dwight/internal/diff"
 	"dwight/internal/ollama"
 	"dwight/internal/storage"
 	s "dwight/internal/styles"
@@ -38,6 +39,7 @@ func (m *model) updateChatLines() {
 	wasAtBottom := len(m.chatLines) == 0 || m.chatScrollPos >= oldMax
 
 	m.chatLines = nil
+	m.refreshBranchInfo()
 
 	if len(m.chatMessages) == 0 && !m.chatStreaming {
 		profile := m.currentProfile()
@@ -106,12 +108,15 @@ func (m *model) updateChatLines() {
 func formatChatMessage(msg *ChatMessage, width int) []string {
 	var lines []string
 
+	if msg.Role == "tool" || msg.Role == "hook" {
+		return formatToolResult(msg, width)
+	}
 	if msg.Role == "user" {
 		timeStr := ""
 		if !msg.Timestamp.IsZero() {
 			timeStr = msg.Timestamp.Format("3:04PM") + " "
 		}
-		lines = append(lines, s.UserMsg.Render(timeStr+"You:"))
+		lines = append(lines, s.UserMsg.Render(timeStr+"You:")+branchLabel(msg))
 		lines = append(lines, wrapText(msg.Content, width)...)
 		lines = append(lines, "")
 	} else {
@@ -129,16 +134,27 @@ func formatChatMessage(msg *ChatMessage, width int) []string {
 			header = fmt.Sprintf("%sdwight: %.1fs · %.0f tok/s · %d tok",
 				timeStr, msg.Duration.Seconds(), tokPerSec, respTokens)
 		}
-		lines = append(lines, s.AssistantMsg.Render(header))
-		rendered := renderMarkdown(msg.Content, width)
-		for _, line := range strings.Split(rendered, "\n") {
-			lines = append(lines, line)
+		lines = append(lines, s.AssistantMsg.Render(header)+branchLabel(msg))
+		if strings.TrimSpace(msg.Content) != "" {
+			rendered := renderMarkdown(msg.Content, width)
+			for _, line := range strings.Split(rendered, "\n") {
+				lines = append(lines, line)
+			}
 		}
+		lines = append(lines, formatToolCalls(msg.ToolCalls, width)...)
 		lines = append(lines, "")
 	}
 	return lines
 }
 
+// branchLabel marks a message that sits at a fork, e.g. " ‹2/3›".
+func branchLabel(msg *ChatMessage) string {
+	if msg.branchCount < 2 {
+		return ""
+	}
+	return s.Dim.Render(fmt.Sprintf(" ‹%d/%d›", msg.branchIdx+1, msg.branchCount))
+}
+
 // renderMarkdown renders markdown content using glamour with a dark theme.
 // Falls back to plain text if glamour fails.
 func renderMarkdown(content string, width int) string {
@@ -376,6 +392,9 @@ func (m *model) saveCurrentChat() error {
 	if m.currentConversation != nil {
 		conv = m.currentConversation
 		conv.Messages = chatToConvMessages(m.chatMessages)
+		conv.Summary = m.chatSummary
+		conv.SummarizedThrough = m.chatSummaryThrough
+		conv.Branches = chatToConvMessages(m.chatBranches)
 	} else {
 		profile := m.currentProfile()
 		convMsgs := chatToConvMessages(m.chatMessages)
@@ -391,6 +410,10 @@ func (m *model) saveCurrentChat() error {
 			WorkingDir:  m.workContext.WorkingDir,
 			GitRoot:     m.workContext.GitRoot,
 			OriginHint:  m.workContext.OriginHint,
+
+			Summary:           m.chatSummary,
+			SummarizedThrough: m.chatSummaryThrough,
+			Branches:          chatToConvMessages(m.chatBranches),
 		}
 		m.currentConversation = conv
 	}
@@ -400,16 +423,34 @@ func (m *model) saveCurrentChat() error {
 func (m *model) resetChatSession() {
 	m.chatMessages = nil
 	m.currentConversation = nil
+	m.chatSummary = ""
+	m.chatSummaryThrough = 0
+	m.chatContextNote = ""
+	m.chatBranches = nil
+	m.chatEditID = ""
+	m.chatTemperature = nil
+	m.chatProfile = ""
+	m.chatSystemPrompt = ""
+	m.showTemplatePicker = false
+	m.templateActive = nil
 	m.attachedResources = nil
 	m.chatStreamBuffer = ""
 	m.chatStreamCh = nil
 	m.chatStreaming = false
+	m.chatStreamToolCalls = nil
+	m.pendingToolCalls = nil
+	m.toolRounds = 0
 	m.cancelChat = nil
 	m.chatCopyMode = false
 	m.chatCopyIdx = 0
 	m.chatCopySelected = nil
 	m.codeBlocks = nil
 	m.reviewIndex = 0
+	m.reviewScroll = 0
+	m.changesetSelected = nil
+	m.reviewWritten = nil
+	m.stopHooks()
+	m.hookFailures = ""
 	m.showResourcePicker = false
 	m.showAtComplete = false
 	m.atCompleteFiles = nil
@@ -423,6 +464,19 @@ func (m *model) resetChatSession() {
 func (m *model) prepareLoadedConversation(conv *storage.Conversation) {
 	m.currentConversation = conv
 	m.chatMessages = convMessagesToChat(conv.Messages)
+	m.chatBranches = convMessagesToChat(conv.Branches)
+	m.chatEditID = ""
+	m.chatSummary = conv.Summary
+	m.chatSummaryThrough = conv.SummarizedThrough
+	m.chatContextNote = ""
+	if conv.Summary != "" {
+		m.chatContextNote = fmt.Sprintf("summarized %d earlier msgs", conv.SummarizedThrough)
+	}
+	m.chatTemperature = nil
+	m.chatProfile = ""
+	m.chatSystemPrompt = ""
+	m.showTemplatePicker = false
+	m.templateActive = nil
 	m.attachedResources = nil
 	m.chatStreamBuffer = ""
 	m.chatStreamCh = nil
@@ -433,6 +487,10 @@ func (m *model) prepareLoadedConversation(conv *storage.Conversation) {
 	m.chatCopySelected = nil
 	m.codeBlocks = nil
 	m.reviewIndex = 0
+	m.reviewScroll = 0
+	m.reviewWritten = nil
+	m.stopHooks()
+	m.hookFailures = ""
 	m.showResourcePicker = false
 	m.showAtComplete = false
 	m.atCompleteFiles = nil
@@ -451,6 +509,9 @@ func (m *model) buildConversationSnapshot() *storage.Conversation {
 	if m.currentConversation != nil {
 		clone := *m.currentConversation
 		clone.Messages = chatToConvMessages(m.chatMessages)
+		clone.Summary = m.chatSummary
+		clone.SummarizedThrough = m.chatSummaryThrough
+		clone.Branches = chatToConvMessages(m.chatBranches)
 		clone.MessageCount = len(clone.Messages)
 		totalTokens, promptTokens := 0, 0
 		for _, msg := range clone.Messages {
@@ -485,6 +546,10 @@ func (m *model) buildConversationSnapshot() *storage.Conversation {
 		WorkingDir:   m.workContext.WorkingDir,
 		GitRoot:      m.workContext.GitRoot,
 		OriginHint:   m.workContext.OriginHint,
+
+		Summary:           m.chatSummary,
+		SummarizedThrough: m.chatSummaryThrough,
+		Branches:          chatToConvMessages(m.chatBranches),
 	}
 }
 
@@ -630,8 +695,10 @@ func chatToConvMessages(msgs []ChatMessage) []storage.ConvMessage {
 	out := make([]storage.ConvMessage, len(msgs))
 	for i, m := range msgs {
 		out[i] = storage.ConvMessage{
+			ID: m.ID, ParentID: m.ParentID,
 			Role: m.Role, Content: m.Content, Timestamp: m.Timestamp,
 			Duration: m.Duration, PromptTokens: m.PromptTokens, TotalTokens: m.TotalTokens,
+			ToolCalls: m.ToolCalls, ToolName: m.ToolName,
 		}
 	}
 	return out
@@ -641,13 +708,127 @@ func convMessagesToChat(msgs []storage.ConvMessage) []ChatMessage {
 	out := make([]ChatMessage, len(msgs))
 	for i, m := range msgs {
 		out[i] = ChatMessage{
+			ID: m.ID, ParentID: m.ParentID,
 			Role: m.Role, Content: m.Content, Timestamp: m.Timestamp,
 			Duration: m.Duration, PromptTokens: m.PromptTokens, TotalTokens: m.TotalTokens,
+			ToolCalls: m.ToolCalls, ToolName: m.ToolName,
 		}
 	}
 	return out
 }
 
+// =============================================================================
+// Message tree
+// =============================================================================
+
+// appendChatMessage adds msg to the end of the active branch.
+func (m *model) appendChatMessage(msg ChatMessage) {
+	msg.ID = storage.NewMessageID()
+	msg.ParentID = ""
+	if n := len(m.chatMessages); n > 0 {
+		msg.ParentID = m.chatMessages[n-1].ID
+	}
+	m.chatMessages = append(m.chatMessages, msg)
+}
+
+func (m *model) chatMessageIndex(id string) int {
+	for i, msg := range m.chatMessages {
+		if msg.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// forkAt moves chatMessages[idx:] onto an inactive branch, so the next
+// appended message becomes a sibling of chatMessages[idx].
+func (m *model) forkAt(idx int) {
+	m.chatBranches = append(m.chatBranches, m.chatMessages[idx:]...)
+	m.chatMessages = m.chatMessages[:idx:idx]
+	m.dropSummaryFrom(idx)
+}
+
+// switchChatBranch activates the sibling delta steps away from chatMessages[idx].
+func (m *model) switchChatBranch(idx, delta int) bool {
+	if idx < 0 || idx >= len(m.chatMessages) {
+		return false
+	}
+	conv := storage.Conversation{
+		Messages: chatToConvMessages(m.chatMessages),
+		Branches: chatToConvMessages(m.chatBranches),
+	}
+	siblings := storage.SiblingIDs(conv.AllMessages(), m.chatMessages[idx].ID)
+	if len(siblings) < 2 {
+		return false
+	}
+	pos := 0
+	for i, id := range siblings {
+		if id == m.chatMessages[idx].ID {
+			pos = i
+		}
+	}
+	next := siblings[(pos+delta+len(siblings))%len(siblings)]
+	if !conv.SwitchBranch(next) {
+		return false
+	}
+	m.chatMessages = convMessagesToChat(conv.Messages)
+	m.chatBranches = convMessagesToChat(conv.Branches)
+	m.dropSummaryFrom(idx)
+	return true
+}
+
+// reattachReply puts the newest existing reply back on the active branch when
+// a generation ends without producing one (e.g. an interrupted regenerate).
+func (m *model) reattachReply() {
+	n := len(m.chatMessages)
+	if n == 0 || m.chatMessages[n-1].Role != "user" || len(m.chatBranches) == 0 {
+		return
+	}
+	conv := storage.Conversation{
+		Messages: chatToConvMessages(m.chatMessages),
+		Branches: chatToConvMessages(m.chatBranches),
+	}
+	if conv.SwitchBranch(m.chatMessages[n-1].ID) {
+		m.chatMessages = convMessagesToChat(conv.Messages)
+		m.chatBranches = convMessagesToChat(conv.Branches)
+	}
+}
+
+// dropSummaryFrom discards the rolling summary when it covers messages at or
+// after idx, since those no longer belong to the active branch.
+func (m *model) dropSummaryFrom(idx int) {
+	if idx < m.chatSummaryThrough {
+		m.chatSummary = ""
+		m.chatSummaryThrough = 0
+		m.chatContextNote = ""
+	}
+}
+
+// refreshBranchInfo records each message's position among its siblings so
+// forks can be labelled in the transcript.
+func (m *model) refreshBranchInfo() {
+	var all []storage.ConvMessage
+	if len(m.chatBranches) > 0 {
+		all = append(chatToConvMessages(m.chatBranches), chatToConvMessages(m.chatMessages)...)
+	}
+	for i := range m.chatMessages {
+		msg := &m.chatMessages[i]
+		idx, count := 0, 1
+		if all != nil {
+			siblings := storage.SiblingIDs(all, msg.ID)
+			for j, id := range siblings {
+				if id == msg.ID {
+					idx, count = j, len(siblings)
+				}
+			}
+		}
+		if idx != msg.branchIdx || count != msg.branchCount {
+			msg.branchIdx, msg.branchCount = idx, count
+			msg.formattedLines = nil
+		}
+	}
+}
+
 // =============================================================================
 // File scanning
 // =============================================================================
@@ -838,6 +1019,14 @@ func fuzzyScore(lPath, lBase, lQuery string) (bool, int) {
 	return true, score
 }
 
+// composeSystemPrompt prefixes a profile's system prompt with the global one.
+func composeSystemPrompt(mainPrompt, profilePrompt string) string {
+	if mainPrompt == "" {
+		return profilePrompt
+	}
+	return mainPrompt + "\n\n" + profilePrompt
+}
+
 // resolveAtReferences expands @path references in a message to file contents.
 func resolveAtReferences(msg string, baseDir string) string {
 	// Find all @path patterns (not preceded by alphanumeric)
@@ -928,42 +1117,171 @@ func extractCodeBlocks(content string) []CodeBlock {
 	return blocks
 }
 
-// generateDiff creates a simple unified diff between old and new content.
-func generateDiff(oldContent, newContent, path string) []string {
-	oldLines := strings.Split(oldContent, "\n")
-	newLines := strings.Split(newContent, "\n")
+// editFormatPrompt teaches the edit-block format resolveCodeBlocks applies.
+const editFormatPrompt = `When you change an existing file, do not repeat the whole file. Send one fenced block per file, tagged with the language and path, containing SEARCH/REPLACE edits:
+
+` + "```go:path/to/file.go" + `
+<<<<<<< SEARCH
+lines copied exactly from the current file
+=======
+the lines that replace them
+>>>>>>> REPLACE
+` + "```" + `
+
+Each SEARCH section must match existing lines, including enough surrounding lines to be unique. Use several SEARCH/REPLACE pairs in one block for several changes. To create a new file, send its complete content in a block tagged with its path.`
+
+// resolveCodeBlocks checks each block's path against the write policy and
+// applies SEARCH/REPLACE blocks and ```diff:path blocks to the files they
+// name, so review shows and writes the whole resulting file rather than the
+// snippet. Later edits to the same file are folded into the first; a block
+// that cannot be applied keeps the reason in Err.
+func (m *model) resolveCodeBlocks(blocks []CodeBlock) []CodeBlock {
+	var out []CodeBlock
+	current := map[string]string{} // path -> content after the edits so far
+	first := map[string]int{}      // path -> index in out of its edit block
+	writes := m.writePolicy()
+	for _, b := range blocks {
+		if b.Path == "" {
+			out = append(out, b)
+			continue
+		}
+		b.Target, b.Denied = writes.Check(b.Path)
+		if b.Denied != nil {
+			out = append(out, b)
+			continue
+		}
 
-	var diff []string
-	diff = append(diff, fmt.Sprintf("--- %s", path))
-	diff = append(diff, fmt.Sprintf("+++ %s", path))
+		var edits []diff.Edit
+		var err error
+		switch {
+		case diff.HasSearchReplace(b.Content):
+			edits, err = diff.ParseSearchReplace(b.Content)
+		case b.Language == "diff" || b.Language == "patch":
+			edits, err = diff.ParseUnified(b.Content)
+		default:
+			out = append(out, b)
+			continue
+		}
 
-	// Simple line-by-line diff (not a real unified diff, but functional)
-	maxLen := len(oldLines)
-	if len(newLines) > maxLen {
-		maxLen = len(newLines)
+		content, seen := current[b.Target.Path]
+		missing := false
+		if !seen && err == nil {
+			data, readErr := os.ReadFile(b.Target.Path)
+			missing = os.IsNotExist(readErr)
+			if readErr != nil && !missing {
+				err = readErr
+			}
+			content = string(data)
+		}
+		if err == nil {
+			content, err = diff.Apply(content, edits)
+			if err != nil && missing {
+				err = fmt.Errorf("%s does not exist", b.Path)
+			}
+		}
+		b.Edit, b.Err = true, err
+		if err != nil {
+			out = append(out, b)
+			continue
+		}
+		current[b.Target.Path] = content
+		b.Content = strings.TrimSuffix(content, "\n")
+		if i, ok := first[b.Target.Path]; ok {
+			out[i].Content = b.Content
+			continue
+		}
+		first[b.Target.Path] = len(out)
+		out = append(out, b)
+	}
+	for i := range out {
+		if b := &out[i]; b.Path != "" && b.Denied == nil && b.Err == nil {
+			existing, err := os.ReadFile(b.Target.Path)
+			b.NewFile = err != nil
+			if !b.NewFile {
+				b.Hunks = diff.Compute(string(existing), b.Content, diff.DefaultContext)
+			}
+		}
 	}
+	return out
+}
 
-	for i := 0; i < maxLen; i++ {
-		var oldLine, newLine string
-		if i < len(oldLines) {
-			oldLine = oldLines[i]
+// editFailurePrompt asks the model to redo an edit block that did not apply.
+func editFailurePrompt(block CodeBlock) string {
+	return fmt.Sprintf("Your edit to %s could not be applied: %v. Re-read the file and send a corrected SEARCH/REPLACE block whose SEARCH text matches the file exactly.", block.Path, block.Err)
+}
+
+// reviewDiffHeight is how many diff lines the review bar shows at once.
+const reviewDiffHeight = 12
+
+// reviewDiffLines renders the change block proposes as colorized diff lines:
+// the hunks resolveCodeBlocks computed against the file on disk, or every
+// line as an addition for a new file. The summary reads e.g. "2 hunks +5 -3". A failed edit block
+// renders its error instead.
+func (m *model) reviewDiffLines(block CodeBlock) (lines []string, summary string) {
+	width := m.safeWidth() - 4
+	if block.Denied != nil {
+		for _, line := range wrapText("Write refused: "+block.Denied.Error(), width) {
+			lines = append(lines, s.Error.Render(line))
+		}
+		return lines, "blocked"
+	}
+	if block.Err != nil {
+		for _, line := range wrapText(block.Err.Error(), width) {
+			lines = append(lines, s.Error.Render(line))
 		}
-		if i < len(newLines) {
-			newLine = newLines[i]
+		lines = append(lines, s.Dim.Render("r: ask the model for a corrected edit | n: skip"))
+		return lines, "edit failed"
+	}
+	if block.NewFile {
+		for _, line := range diff.Split(block.Content) {
+			lines = append(lines, s.DiffAdd.Render(truncateStr("+"+line, width)))
 		}
+		return lines, fmt.Sprintf("new file +%d", len(lines))
+	}
+	hunks := block.Hunks
+	if len(hunks) == 0 {
+		return []string{s.Dim.Render("  no changes")}, "unchanged"
+	}
+	for _, h := range hunks {
+		lines = append(lines, s.DiffHunk.Render(h.Header()))
+		for _, l := range h.Lines {
+			text := truncateStr(l.Kind.Prefix()+l.Text, width)
+			switch l.Kind {
+			case diff.Insert:
+				lines = append(lines, s.DiffAdd.Render(text))
+			case diff.Delete:
+				lines = append(lines, s.DiffRemove.Render(text))
+			default:
+				lines = append(lines, s.Dim.Render(text))
+			}
+		}
+	}
+	added, removed := diff.Stats(hunks)
+	noun := "hunks"
+	if len(hunks) == 1 {
+		noun = "hunk"
+	}
+	return lines, fmt.Sprintf("%d %s +%d -%d", len(hunks), noun, added, removed)
+}
 
-		if i >= len(oldLines) {
-			diff = append(diff, "+ "+newLine)
-		} else if i >= len(newLines) {
-			diff = append(diff, "- "+oldLine)
-		} else if oldLine != newLine {
-			diff = append(diff, "- "+oldLine)
-			diff = append(diff, "+ "+newLine)
-		} else {
-			diff = append(diff, "  "+oldLine)
+// currentReviewBlock returns the block under review, skipping blocks without a path.
+func (m *model) currentReviewBlock() (CodeBlock, bool) {
+	for idx := m.reviewIndex; idx < len(m.codeBlocks); idx++ {
+		if m.codeBlocks[idx].Path != "" {
+			return m.codeBlocks[idx], true
 		}
 	}
-	return diff
+	return CodeBlock{}, false
+}
+
+// scrollReview moves the review diff by delta lines, clamped to its length.
+func (m *model) scrollReview(delta int) {
+	block, ok := m.currentReviewBlock()
+	if !ok {
+		return
+	}
+	lines, _ := m.reviewDiffLines(block)
+	m.reviewScroll = max(0, min(m.reviewScroll+delta, len(lines)-reviewDiffHeight))
 }
 
 // hasActionableBlocks returns true if any code block has a detected file path.
@@ -997,6 +1315,7 @@ func (m model) acceptCodeBlock() (tea.Model, tea.Cmd) {
 	// Find next block with a path
 	for m.reviewIndex < len(m.codeBlocks) && m.codeBlocks[m.reviewIndex].Path == "" {
 		m.reviewIndex++
+		m.reviewScroll = 0
 	}
 	if m.reviewIndex >= len(m.codeBlocks) {
 		m.chatState = ChatStateReady
@@ -1005,32 +1324,55 @@ func (m model) acceptCodeBlock() (tea.Model, tea.Cmd) {
 	}
 
 	block := m.codeBlocks[m.reviewIndex]
-	fullPath := filepath.Join(m.currentDir, block.Path)
-
-	// Ensure parent directory exists
-	os.MkdirAll(filepath.Dir(fullPath), 0755)
-
-	if err := os.WriteFile(fullPath, []byte(block.Content+"\n"), 0644); err != nil {
+	err := block.Denied
+	if err == nil {
+		err = block.Err
+	}
+	if err == nil {
+		err = m.writeAccepted(block.Target.Path, block.Content+"\n")
+	}
+	if err != nil {
 		m.reviewIndex++
+		m.reviewScroll = 0
+		var hooks tea.Cmd
 		if !hasMoreActionableBlocks(m.codeBlocks, m.reviewIndex) {
-			m.chatState = ChatStateReady
-			m.codeBlocks = nil
-			m.chatTextArea.Focus()
+			hooks = m.endReview()
+		}
+		if block.Denied != nil {
+			return m, tea.Batch(showStatus(fmt.Sprintf("Cannot write %s: %v", block.Path, err)), hooks)
+		}
+		if block.Err != nil {
+			return m, tea.Batch(showStatus(fmt.Sprintf("Failed to apply edit to %s: %v", block.Path, err)), hooks)
 		}
-		return m, showStatus(fmt.Sprintf("Failed to write %s: %v", block.Path, err))
+		return m, tea.Batch(showStatus(fmt.Sprintf("Failed to write %s: %v", block.Path, err)), hooks)
 	}
 
-	msg := fmt.Sprintf("Wrote %s", block.Path)
+	msg := fmt.Sprintf("Wrote %s (alt+u to undo)", block.Path)
+	m.reviewWritten = append(m.reviewWritten, block.Target.Path)
 	m.reviewIndex++
+	m.reviewScroll = 0
+	var hooks tea.Cmd
 	if !hasMoreActionableBlocks(m.codeBlocks, m.reviewIndex) {
-		m.chatState = ChatStateReady
-		m.codeBlocks = nil
-		m.reviewIndex = 0
-		m.chatTextArea.Focus()
+		if hooks = m.endReview(); hooks != nil {
+			msg += ", running hooks"
+		}
 	}
 	// Invalidate file cache since we wrote a file
 	m.fileCache = nil
-	return m, showStatus(msg)
+	return m, tea.Batch(showStatus(msg), hooks)
+}
+
+// endReview leaves review mode and runs the post-apply hooks on the files
+// accepted during it.
+func (m *model) endReview() tea.Cmd {
+	m.chatState = ChatStateReady
+	m.codeBlocks = nil
+	m.reviewIndex = 0
+	m.reviewScroll = 0
+	m.chatTextArea.Focus()
+	written := m.reviewWritten
+	m.reviewWritten = nil
+	return m.runPostApplyHooks(written)
 }
 
 func (m *model) getFilteredLibrary() []ollama.LibraryModel {
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dwight/internal/policy"
+	"dwight/internal/tools"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// =============================================================================
+// Post-apply hooks — format, build and test after accepted code is written
+// =============================================================================
+
+// maxHookFailure caps how much of one failing hook is sent back to the model.
+const maxHookFailure = 8000
+
+type hookResult struct {
+	command string
+	output  string // run_command style: "$ cmd", "exit code: N", stdout, stderr
+	failed  bool
+}
+
+type hooksDoneMsg struct {
+	runID   int
+	results []hookResult
+}
+
+// hookRun is one expanded hook command.
+type hookRun struct {
+	command string
+	timeout int // seconds; 0 for the run_command default
+}
+
+// hookCommands expands the configured hooks for the files just written,
+// in order and without duplicates.
+func (m *model) hookCommands(paths []string) []hookRun {
+	root := m.projectRoot()
+	var rels []string
+	for _, p := range paths {
+		if rel, err := filepath.Rel(root, p); err == nil && filepath.IsLocal(rel) {
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+	}
+
+	var runs []hookRun
+	add := func(command string, timeout int) {
+		for _, r := range runs {
+			if r.command == command {
+				return
+			}
+		}
+		runs = append(runs, hookRun{command, timeout})
+	}
+	for _, h := range m.config.HooksFor(root) {
+		command := strings.TrimSpace(h.Command)
+		if command == "" {
+			continue
+		}
+		var matched []string
+		for _, rel := range rels {
+			if h.Match == "" || policy.Match(h.Match, rel) {
+				matched = append(matched, rel)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		if !strings.Contains(command, "{file}") {
+			add(command, h.Timeout)
+			continue
+		}
+		for _, rel := range matched {
+			add(strings.ReplaceAll(command, "{file}", shellQuote(rel)), h.Timeout)
+		}
+	}
+	return runs
+}
+
+// shellQuote quotes s for sh unless it is plainly safe.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runPostApplyHooks runs the hooks that match paths, one after another,
+// stopping at the first failure since later steps (build, test) would only
+// fail the same way. It returns nil when no hook applies.
+func (m *model) runPostApplyHooks(paths []string) tea.Cmd {
+	runs := m.hookCommands(paths)
+	if len(runs) == 0 {
+		return nil
+	}
+	m.stopHooks()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelHooks = cancel
+	m.hooksRunning = true
+	m.hookFailures = ""
+	runID := m.hookRunID
+	root := m.projectRoot()
+	return func() tea.Msg {
+		defer cancel()
+		var results []hookResult
+		for _, run := range runs {
+			if ctx.Err() != nil {
+				break
+			}
+			args := map[string]any{"command": run.command, "timeout": run.timeout}
+			out, err := tools.Run(ctx, root, "run_command", args)
+			if err != nil {
+				out = fmt.Sprintf("$ %s\nerror: %v", run.command, err)
+			}
+			r := hookResult{command: run.command, output: out, failed: err != nil || tools.ExitCode(out) != 0}
+			results = append(results, r)
+			if r.failed {
+				break
+			}
+		}
+		return hooksDoneMsg{runID: runID, results: results}
+	}
+}
+
+// stopHooks cancels the running hooks, if any, and makes sure their results
+// are dropped when they arrive.
+func (m *model) stopHooks() {
+	if m.cancelHooks != nil {
+		m.cancelHooks()
+		m.cancelHooks = nil
+	}
+	m.hooksRunning = false
+	m.hookRunID++
+}
+
+// finishHooks shows the hook output in the transcript and remembers failures
+// for alt+f. Results of a cancelled run, or of a run started in another
+// session, are dropped; while a reply is in progress they wait until the
+// chat is ready so they don't land inside the turn.
+func (m model) finishHooks(msg hooksDoneMsg) (tea.Model, tea.Cmd) {
+	if msg.runID != m.hookRunID || !m.hooksRunning {
+		return m, nil
+	}
+	if m.chatState != ChatStateReady || m.chatStreaming {
+		return m, tea.Tick(250*time.Millisecond, func(time.Time) tea.Msg { return msg })
+	}
+	m.hooksRunning = false
+	m.cancelHooks = nil
+	var failures []string
+	failed := ""
+	for _, r := range msg.results {
+		m.appendChatMessage(ChatMessage{Role: "hook", Content: r.output, Timestamp: time.Now()})
+		if r.failed {
+			failed = r.command
+			out := r.output
+			if len(out) > maxHookFailure {
+				out = out[:maxHookFailure] + "\n[... output truncated]"
+			}
+			failures = append(failures, out)
+		}
+	}
+	m.hookFailures = strings.Join(failures, "\n\n")
+	m.updateChatLines()
+	if failed != "" {
+		return m, showStatus(fmt.Sprintf("Failed hook: %s (alt+f asks the model to fix it)", failed))
+	}
+	return m, showStatus(fmt.Sprintf("Hooks passed (%d)", len(msg.results)))
+}
+
+// sendHookFailures asks the model to fix what the last hooks reported.
+func (m model) sendHookFailures() (tea.Model, tea.Cmd) {
+	if m.hookFailures == "" {
+		return m, showStatus("No hook failures to send")
+	}
+	content := "These checks failed after your changes were applied:\n\n```\n" + m.hookFailures + "\n```\n\nPlease fix the errors."
+	m.hookFailures = ""
+	m.appendChatMessage(ChatMessage{Role: "user", Content: content, Timestamp: time.Now()})
+	m.chatState = ChatStateLoading
+	m.updateChatLines()
+	return m, tea.Batch(m.sendChat(), m.chatSpinner.Tick)
+}
@@ -0,0 +1,290 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"dwight/internal/endpoint"
+)
+
+const (
+	defaultBaseURL   = "https://api.anthropic.com"
+	apiVersion       = "2023-06-01"
+	defaultMaxTokens = 4096
+)
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	Endpoint    endpoint.Endpoint
+	Model       string
+	Messages    []ChatMessage
+	System      string
+	Temperature float64
+	MaxTokens   int
+	// Optional sampling fields; zero values are omitted.
+	TopP          float64
+	TopK          int
+	StopSequences []string
+	Timeout       time.Duration
+}
+
+type StreamChunk struct {
+	Content      string
+	Done         bool
+	Duration     time.Duration
+	PromptTokens int
+	TotalTokens  int
+	Err          error
+}
+
+// Model is an entry from GET /v1/models.
+type Model struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// BaseURL resolves the API root (without /v1). An explicit value wins, then
+// ANTHROPIC_BASE_URL, then the public API.
+func BaseURL(explicit string) string {
+	return strings.TrimSuffix(endpoint.Resolve(explicit, "ANTHROPIC_BASE_URL", defaultBaseURL), "/v1")
+}
+
+func APIKey(explicit string) string {
+	return endpoint.Key(explicit, "ANTHROPIC_API_KEY")
+}
+
+// CheckModel validates the profile. A key is only required against the public
+// API so local stand-in servers work without one.
+func CheckModel(ep endpoint.Endpoint, modelName string) error {
+	if strings.TrimSpace(modelName) == "" {
+		return fmt.Errorf("Anthropic profile is missing a model name")
+	}
+	if APIKey(ep.APIKey) == "" && BaseURL(ep.BaseURL) == defaultBaseURL {
+		return fmt.Errorf("Anthropic API key missing. Set ANTHROPIC_API_KEY, then retry")
+	}
+	return nil
+}
+
+func newRequest(ctx context.Context, ep endpoint.Endpoint, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, BaseURL(ep.BaseURL)+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("anthropic-version", apiVersion)
+	if key := APIKey(ep.APIKey); key != "" {
+		req.Header.Set("x-api-key", key)
+	}
+	ep.ApplyHeaders(req)
+	return req, nil
+}
+
+// ListModels returns the models available to the API key.
+func ListModels(ctx context.Context, ep endpoint.Endpoint) ([]Model, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := newRequest(ctx, ep, http.MethodGet, "/v1/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Anthropic: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Anthropic API error: %d %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result struct {
+		Data []Model `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return result.Data, nil
+}
+
+func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
+	if err := CheckModel(req.Endpoint, req.Model); err != nil {
+		return nil, err
+	}
+
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = 180 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	body, err := json.Marshal(buildPayload(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", err)
+	}
+
+	httpReq, err := newRequest(ctx, req.Endpoint, http.MethodPost, "/v1/messages", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Anthropic API error: %d %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	ch := make(chan StreamChunk)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ch)
+
+		startTime := time.Now()
+		var inputTokens, outputTokens int
+		scanner := bufio.NewScanner(resp.Body)
+		const maxScanToken = 1024 * 1024
+		buf := make([]byte, 0, 64*1024)
+		scanner.Buffer(buf, maxScanToken)
+
+		// Events arrive as "event: <type>" / "data: <json>" pairs; the JSON
+		// repeats the type, so only data lines are needed.
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "" {
+				continue
+			}
+
+			var ev streamEvent
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				ch <- StreamChunk{Err: fmt.Errorf("failed to parse Anthropic stream: %v", err)}
+				return
+			}
+
+			switch ev.Type {
+			case "message_start":
+				inputTokens = ev.Message.Usage.InputTokens
+				outputTokens = ev.Message.Usage.OutputTokens
+			case "content_block_delta":
+				if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+					ch <- StreamChunk{Content: ev.Delta.Text}
+				}
+			case "message_delta":
+				if ev.Usage.OutputTokens > 0 {
+					outputTokens = ev.Usage.OutputTokens
+				}
+			case "message_stop":
+				ch <- StreamChunk{
+					Done:         true,
+					Duration:     time.Since(startTime),
+					PromptTokens: inputTokens,
+					TotalTokens:  inputTokens + outputTokens,
+				}
+				return
+			case "error":
+				ch <- StreamChunk{Err: fmt.Errorf("Anthropic API error: %s: %s", ev.Error.Type, ev.Error.Message)}
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			ch <- StreamChunk{Err: fmt.Errorf("Anthropic stream failed: %v", err)}
+			return
+		}
+
+		// Stream ended without message_stop — still report what we have.
+		ch <- StreamChunk{
+			Done:         true,
+			Duration:     time.Since(startTime),
+			PromptTokens: inputTokens,
+			TotalTokens:  inputTokens + outputTokens,
+		}
+	}()
+
+	return ch, nil
+}
+
+func buildPayload(req ChatRequest) map[string]interface{} {
+	messages := make([]ChatMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		role := "user"
+		if strings.EqualFold(msg.Role, "assistant") || strings.EqualFold(msg.Role, "model") {
+			role = "assistant"
+		}
+		messages = append(messages, ChatMessage{Role: role, Content: msg.Content})
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	payload := map[string]interface{}{
+		"model":       req.Model,
+		"messages":    messages,
+		"max_tokens":  maxTokens,
+		"temperature": req.Temperature,
+		"stream":      true,
+	}
+
+	if req.TopP > 0 {
+		payload["top_p"] = req.TopP
+	}
+	if req.TopK > 0 {
+		payload["top_k"] = req.TopK
+	}
+	if len(req.StopSequences) > 0 {
+		payload["stop_sequences"] = req.StopSequences
+	}
+
+	if strings.TrimSpace(req.System) != "" {
+		payload["system"] = req.System
+	}
+
+	return payload
+}
+
+type streamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage usage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage usage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
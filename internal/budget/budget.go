@@ -0,0 +1,145 @@
+// Package budget keeps chat history inside a model's context window, either by
+// dropping the oldest turns or by folding them into a rolling summary.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"dwight/internal/provider"
+)
+
+// Strategies for history that no longer fits.
+const (
+	StrategySummarize = "summarize"
+	StrategyTruncate  = "truncate"
+	StrategyOff       = "off"
+)
+
+// perMessageOverhead approximates the role/formatting tokens each turn costs.
+const perMessageOverhead = 4
+
+// NormalizeStrategy maps user input onto a known strategy; empty means summarize.
+func NormalizeStrategy(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", StrategySummarize, "summary":
+		return StrategySummarize
+	case StrategyTruncate, "drop":
+		return StrategyTruncate
+	case StrategyOff, "none":
+		return StrategyOff
+	default:
+		return ""
+	}
+}
+
+// Limit is the prompt budget for a context window, leaving a quarter of it
+// for the reply. It returns 0 (no limit) when the window is unknown.
+func Limit(contextSize int) int {
+	return contextSize * 3 / 4
+}
+
+// EstimateTokens approximates the token count of s (~4 bytes per token).
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// MessageTokens estimates the cost of a single turn.
+func MessageTokens(m provider.Message) int {
+	return EstimateTokens(m.Content) + perMessageOverhead
+}
+
+// Fit returns the index of the first history message to keep so that
+// history[cut:] plus fixed tokens stays within limit. The cut is moved forward
+// to a user turn so a kept reply never loses its question. A limit of 0
+// keeps everything.
+func Fit(history []provider.Message, fixed, limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	used := fixed
+	cut := len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		used += MessageTokens(history[i])
+		if used > limit {
+			break
+		}
+		cut = i
+	}
+	if cut > 0 {
+		for cut < len(history) && history[cut].Role != "user" {
+			cut++
+		}
+	}
+	return cut
+}
+
+// WithSummary pins a conversation summary onto the system prompt.
+func WithSummary(system, summary string) string {
+	if strings.TrimSpace(summary) == "" {
+		return system
+	}
+	block := "=== CONVERSATION SUMMARY ===\n" + strings.TrimSpace(summary) +
+		"\n=== END SUMMARY ===\nEarlier turns were condensed into this summary; treat it as shared context."
+	if strings.TrimSpace(system) == "" {
+		return block
+	}
+	return system + "\n\n" + block
+}
+
+const summarizerPrompt = `You maintain a running summary of a conversation between a user and an AI assistant.
+Merge the previous summary (if any) with the new messages into one concise summary.
+Keep decisions, facts, file names, code identifiers, constraints and open questions.
+Reply with the summary only, no preamble.`
+
+// Summarize asks the model to fold msgs into previous. limit caps the size of
+// the summarization request; the oldest text is cut first when it overflows.
+func Summarize(ctx context.Context, backend provider.Provider, req provider.ChatRequest, previous string, msgs []provider.Message, limit int) (string, error) {
+	var transcript strings.Builder
+	for _, m := range msgs {
+		speaker := "User"
+		if m.Role == "assistant" {
+			speaker = "Assistant"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", speaker, strings.TrimSpace(m.Content))
+	}
+	text := transcript.String()
+	if limit > 0 {
+		room := (limit - EstimateTokens(summarizerPrompt) - EstimateTokens(previous) - 64) * 4
+		if room < 0 {
+			room = 0
+		}
+		if len(text) > room {
+			text = "[...]\n" + text[len(text)-room:]
+		}
+	}
+
+	var prompt strings.Builder
+	if strings.TrimSpace(previous) != "" {
+		prompt.WriteString("Previous summary:\n" + strings.TrimSpace(previous) + "\n\n")
+	}
+	prompt.WriteString("New messages:\n" + text)
+
+	req.System = summarizerPrompt
+	req.Messages = []provider.Message{{Role: "user", Content: prompt.String()}}
+	req.Tools = nil
+	req.Temperature = 0.2
+
+	ch, err := backend.ChatStream(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	for chunk := range ch {
+		if chunk.Err != nil {
+			return "", chunk.Err
+		}
+		out.WriteString(chunk.Content)
+	}
+	summary := strings.TrimSpace(out.String())
+	if summary == "" {
+		return "", fmt.Errorf("model returned an empty summary")
+	}
+	return summary, nil
+}
@@ -0,0 +1,288 @@
+// Package diff computes line diffs (Myers' algorithm) and renders them as
+// unified-diff hunks for reviewing proposed file changes.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines kept around each change.
+const DefaultContext = 3
+
+// maxEditDistance bounds the Myers search. Past it the remaining middle of
+// the files is reported as one delete-all/insert-all block, which keeps huge
+// rewrites from using quadratic memory.
+const maxEditDistance = 1000
+
+// Kind says how a line changed.
+type Kind int
+
+const (
+	Equal Kind = iota
+	Insert
+	Delete
+)
+
+// Line is one line of a diff. OldLine/NewLine are 1-based and 0 when the
+// line does not exist on that side.
+type Line struct {
+	Kind    Kind
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// Hunk is a run of changes plus surrounding context.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// Header renders the hunk's "@@ -a,b +c,d @@" line.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", span(h.OldStart, h.OldLines), span(h.NewStart, h.NewLines))
+}
+
+func span(start, n int) string {
+	if n == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
+
+// Split breaks content into lines, ignoring a trailing newline.
+func Split(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// Lines returns the full line-by-line edit script turning a into b.
+func Lines(a, b []string) []Line {
+	// Common prefix and suffix need no search.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	out := make([]Line, 0, len(a)+len(b))
+	for i := 0; i < pre; i++ {
+		out = append(out, Line{Kind: Equal, Text: a[i], OldLine: i + 1, NewLine: i + 1})
+	}
+	for _, l := range myers(a[pre:len(a)-suf], b[pre:len(b)-suf]) {
+		if l.OldLine > 0 {
+			l.OldLine += pre
+		}
+		if l.NewLine > 0 {
+			l.NewLine += pre
+		}
+		out = append(out, l)
+	}
+	for i := 0; i < suf; i++ {
+		ai, bi := len(a)-suf+i, len(b)-suf+i
+		out = append(out, Line{Kind: Equal, Text: a[ai], OldLine: ai + 1, NewLine: bi + 1})
+	}
+	return out
+}
+
+// myers finds a shortest edit script with the greedy O(ND) algorithm, keeping
+// each round's frontier to backtrack through.
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	found := false
+	for d := 0; d <= n+m && d <= maxEditDistance; d++ {
+		snapshot := make([]int, 2*d+1)
+		for k := -d; k <= d; k++ {
+			snapshot[k+d] = v[k+offset]
+		}
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset] // step down: insert
+			} else {
+				x = v[k-1+offset] + 1 // step right: delete
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		return replaceAll(a, b)
+	}
+
+	// Backtrack from (n, m) through the saved frontiers.
+	var rev []Line
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, Line{Kind: Equal, Text: a[x-1], OldLine: x, NewLine: y})
+			x--
+			y--
+		}
+		if x == prevX {
+			rev = append(rev, Line{Kind: Insert, Text: b[y-1], NewLine: y})
+		} else {
+			rev = append(rev, Line{Kind: Delete, Text: a[x-1], OldLine: x})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		rev = append(rev, Line{Kind: Equal, Text: a[x-1], OldLine: x, NewLine: y})
+		x--
+		y--
+	}
+
+	out := make([]Line, len(rev))
+	for i, l := range rev {
+		out[len(rev)-1-i] = l
+	}
+	return out
+}
+
+func replaceAll(a, b []string) []Line {
+	out := make([]Line, 0, len(a)+len(b))
+	for i, t := range a {
+		out = append(out, Line{Kind: Delete, Text: t, OldLine: i + 1})
+	}
+	for i, t := range b {
+		out = append(out, Line{Kind: Insert, Text: t, NewLine: i + 1})
+	}
+	return out
+}
+
+// Compute diffs two file contents into hunks with context lines around each
+// change. Identical contents give no hunks.
+func Compute(oldContent, newContent string, context int) []Hunk {
+	lines := Lines(Split(oldContent), Split(newContent))
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == Equal {
+			i++
+			continue
+		}
+		// Grow the hunk until a gap of unchanged lines is wider than two contexts.
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].Kind != Equal {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		stop := min(end+context+1, len(lines))
+		hunks = append(hunks, newHunk(lines[start:stop], lines[:start]))
+		i = stop
+	}
+	return hunks
+}
+
+// newHunk counts the hunk's lines; before is everything preceding it, used to
+// find its starting line numbers when a side is empty.
+func newHunk(lines, before []Line) Hunk {
+	h := Hunk{Lines: lines}
+	oldPos, newPos := 0, 0
+	for _, l := range before {
+		if l.Kind != Insert {
+			oldPos++
+		}
+		if l.Kind != Delete {
+			newPos++
+		}
+	}
+	for _, l := range lines {
+		if l.Kind != Insert {
+			h.OldLines++
+		}
+		if l.Kind != Delete {
+			h.NewLines++
+		}
+	}
+	// Unified diff numbers an empty side by the line before it.
+	h.OldStart, h.NewStart = oldPos+1, newPos+1
+	if h.OldLines == 0 {
+		h.OldStart = oldPos
+	}
+	if h.NewLines == 0 {
+		h.NewStart = newPos
+	}
+	return h
+}
+
+// Stats counts added and removed lines across hunks.
+func Stats(hunks []Hunk) (added, removed int) {
+	for _, h := range hunks {
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case Insert:
+				added++
+			case Delete:
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// Prefix is the unified-diff marker for a line kind.
+func (k Kind) Prefix() string {
+	switch k {
+	case Insert:
+		return "+"
+	case Delete:
+		return "-"
+	}
+	return " "
+}
+
+// Unified renders hunks as unified diff text lines, headers included.
+func Unified(path string, hunks []Hunk) []string {
+	if len(hunks) == 0 {
+		return nil
+	}
+	out := []string{"--- a/" + path, "+++ b/" + path}
+	for _, h := range hunks {
+		out = append(out, h.Header())
+		for _, l := range h.Lines {
+			out = append(out, l.Kind.Prefix()+l.Text)
+		}
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// script renders an edit script compactly, e.g. " a -b +c".
+func script(lines []Line) string {
+	var parts []string
+	for _, l := range lines {
+		parts = append(parts, l.Kind.Prefix()+l.Text)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb", "a\nb", " a  b"},
+		{"both empty", "", "", ""},
+		{"insert into empty", "", "a\nb", "+a +b"},
+		{"delete all", "a\nb", "", "-a -b"},
+		{"insert in middle", "a\nc", "a\nb\nc", " a +b  c"},
+		{"delete in middle", "a\nb\nc", "a\nc", " a -b  c"},
+		{"replace", "a\nb\nc", "a\nx\nc", " a -b +x  c"},
+		{"move", "a\nb\nc", "b\nc\na", "-a  b  c +a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Split(tt.a), Split(tt.b)
+			lines := Lines(a, b)
+			if got := script(lines); got != tt.want {
+				t.Errorf("Lines = %q, want %q", got, tt.want)
+			}
+			// The script must rebuild both sides with correct line numbers.
+			var oldSide, newSide []string
+			for _, l := range lines {
+				if l.Kind != Insert {
+					oldSide = append(oldSide, l.Text)
+					if l.OldLine != len(oldSide) {
+						t.Errorf("%q has OldLine %d, want %d", l.Text, l.OldLine, len(oldSide))
+					}
+				}
+				if l.Kind != Delete {
+					newSide = append(newSide, l.Text)
+					if l.NewLine != len(newSide) {
+						t.Errorf("%q has NewLine %d, want %d", l.Text, l.NewLine, len(newSide))
+					}
+				}
+			}
+			if strings.Join(oldSide, "\n") != strings.Join(a, "\n") || strings.Join(newSide, "\n") != strings.Join(b, "\n") {
+				t.Errorf("script does not rebuild the inputs: old %q, new %q", oldSide, newSide)
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	numbered := func(n int, change map[int]string) string {
+		var b strings.Builder
+		for i := 1; i <= n; i++ {
+			if s, ok := change[i]; ok {
+				b.WriteString(s + "\n")
+				continue
+			}
+			b.WriteString("line" + strings.Repeat("x", i) + "\n")
+		}
+		return b.String()
+	}
+	tests := []struct {
+		name           string
+		old, new       string
+		headers        []string
+		added, removed int
+	}{
+		{"identical", "a\nb\n", "a\nb\n", nil, 0, 0},
+		{"new file", "", "a\nb\n", []string{"@@ -0,0 +1,2 @@"}, 2, 0},
+		{"emptied file", "a\nb\n", "", []string{"@@ -1,2 +0,0 @@"}, 0, 2},
+		{"one change", numbered(10, nil), numbered(10, map[int]string{5: "five"}), []string{"@@ -2,7 +2,7 @@"}, 1, 1},
+		{"close changes merge", numbered(12, nil), numbered(12, map[int]string{3: "c", 8: "h"}), []string{"@@ -1,11 +1,11 @@"}, 2, 2},
+		{"far changes split", numbered(20, nil), numbered(20, map[int]string{2: "b", 18: "r"}),
+			[]string{"@@ -1,5 +1,5 @@", "@@ -15,6 +15,6 @@"}, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := Compute(tt.old, tt.new, DefaultContext)
+			var headers []string
+			for _, h := range hunks {
+				headers = append(headers, h.Header())
+			}
+			if strings.Join(headers, " ") != strings.Join(tt.headers, " ") {
+				t.Errorf("headers = %q, want %q", headers, tt.headers)
+			}
+			if added, removed := Stats(hunks); added != tt.added || removed != tt.removed {
+				t.Errorf("Stats = +%d -%d, want +%d -%d", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Edit replaces the lines Search with Replace. An empty Search appends.
+type Edit struct {
+	Search  string
+	Replace string
+}
+
+// Edit-block markers, as taught to models:
+//
+//	<<<<<<< SEARCH
+//	old lines
+//	=======
+//	new lines
+//	>>>>>>> REPLACE
+var (
+	searchMarker  = regexp.MustCompile(`^\s*<{5,9} ?SEARCH\s*$`)
+	dividerMarker = regexp.MustCompile(`^\s*={5,9}\s*$`)
+	replaceMarker = regexp.MustCompile(`^\s*>{5,9} ?REPLACE\s*$`)
+)
+
+// hunkHeader captures the line counts of a unified diff hunk header.
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// HasSearchReplace reports whether body contains SEARCH/REPLACE blocks.
+func HasSearchReplace(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if searchMarker.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSearchReplace reads the SEARCH/REPLACE blocks in body.
+func ParseSearchReplace(body string) ([]Edit, error) {
+	const (
+		outside = iota
+		inSearch
+		inReplace
+	)
+	var edits []Edit
+	var search, replace []string
+	state := outside
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case searchMarker.MatchString(line):
+			if state != outside {
+				return nil, fmt.Errorf("SEARCH block %d is not closed with >>>>>>> REPLACE", len(edits)+1)
+			}
+			state, search, replace = inSearch, nil, nil
+		case state == inSearch && dividerMarker.MatchString(line):
+			state = inReplace
+		case state == inReplace && replaceMarker.MatchString(line):
+			edits = append(edits, Edit{Search: strings.Join(search, "\n"), Replace: strings.Join(replace, "\n")})
+			state = outside
+		case state == inSearch:
+			search = append(search, line)
+		case state == inReplace:
+			replace = append(replace, line)
+		}
+	}
+	if state != outside {
+		return nil, fmt.Errorf("SEARCH block %d is not closed with >>>>>>> REPLACE", len(edits)+1)
+	}
+	return edits, nil
+}
+
+// ParseUnified turns the hunks of a unified diff into edits. Line numbers in
+// the @@ headers are ignored since models rarely get them right; each hunk is
+// located by its context and removed lines instead. Inside a hunk, a
+// "--- "/"+++ " pair is a file header only once the hunk's line counts are
+// used up or when a new hunk follows it; otherwise it is a removed and an
+// added line.
+func ParseUnified(patch string) ([]Edit, error) {
+	var edits []Edit
+	var search, replace []string
+	inHunk := false
+	counted, oldLeft, newLeft := false, 0, 0
+	flush := func() {
+		if inHunk && (len(search) > 0 || len(replace) > 0) {
+			edits = append(edits, Edit{Search: strings.Join(search, "\n"), Replace: strings.Join(replace, "\n")})
+		}
+		search, replace = nil, nil
+	}
+	lines := strings.Split(strings.TrimRight(patch, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			flush()
+			inHunk = true
+			counted, oldLeft, newLeft = hunkCounts(line)
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") &&
+			(!inHunk || counted && oldLeft <= 0 && newLeft <= 0 || i+2 < len(lines) && strings.HasPrefix(lines[i+2], "@@")):
+			// file header pair starting the next file
+			flush()
+			inHunk = false
+		case !inHunk, strings.HasPrefix(line, `\ No newline`):
+		case line == "":
+			// blank context line whose leading space was lost
+			search = append(search, "")
+			replace = append(replace, "")
+			oldLeft, newLeft = oldLeft-1, newLeft-1
+		case line[0] == '+':
+			replace = append(replace, line[1:])
+			newLeft--
+		case line[0] == '-':
+			search = append(search, line[1:])
+			oldLeft--
+		case line[0] == ' ':
+			search = append(search, line[1:])
+			replace = append(replace, line[1:])
+			oldLeft, newLeft = oldLeft-1, newLeft-1
+		default:
+			return nil, fmt.Errorf("unexpected line in diff: %q", line)
+		}
+	}
+	flush()
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("diff has no hunks")
+	}
+	return edits, nil
+}
+
+// hunkCounts reads the old and new line counts from a hunk header. ok is
+// false for headers without line numbers, such as a bare "@@ ... @@".
+func hunkCounts(header string) (ok bool, old, new int) {
+	m := hunkHeader.FindStringSubmatch(header)
+	if m == nil {
+		return false, 0, 0
+	}
+	old, new = 1, 1
+	if m[1] != "" {
+		old, _ = strconv.Atoi(m[1])
+	}
+	if m[2] != "" {
+		new, _ = strconv.Atoi(m[2])
+	}
+	return true, old, new
+}
+
+// Apply makes each edit in turn. Search text is matched line by line, first
+// exactly and then ignoring differences in whitespace; a whitespace match
+// re-indents the replacement to fit the file. Search text that occurs more
+// than once is refused, since it is unclear which occurrence was meant.
+func Apply(content string, edits []Edit) (string, error) {
+	trailingNewline := strings.HasSuffix(content, "\n") || content == ""
+	lines := Split(content)
+	for i, e := range edits {
+		search := Split(e.Search)
+		replace := Split(e.Replace)
+		if len(trimBlank(search)) == 0 {
+			lines = append(lines, replace...)
+			continue
+		}
+		at, n, reindent := find(lines, search)
+		switch at {
+		case notFoundAt:
+			return "", searchError(i, len(edits), search, "not found in the file")
+		case ambiguousAt:
+			return "", searchError(i, len(edits), search, "matches more than once; add more context")
+		}
+		if reindent != nil {
+			replace = reindent(replace)
+		}
+		lines = append(lines[:at], append(append([]string(nil), replace...), lines[at+n:]...)...)
+	}
+	out := strings.Join(lines, "\n")
+	if trailingNewline && out != "" {
+		out += "\n"
+	}
+	return out, nil
+}
+
+// Positions find reports instead of a match.
+const (
+	notFoundAt  = -1
+	ambiguousAt = -2
+)
+
+// find locates search in lines and returns its start, how many file lines it
+// covers, and for a whitespace-insensitive match a function re-indenting the
+// replacement. It returns notFoundAt or ambiguousAt when there is no single
+// match.
+func find(lines, search []string) (at, n int, reindent func([]string) []string) {
+	if at := match(lines, search, func(a, b string) bool { return a == b }); at != notFoundAt {
+		return at, len(search), nil
+	}
+	search = trimBlank(search)
+	at = match(lines, search, func(a, b string) bool { return normalize(a) == normalize(b) })
+	if at < 0 {
+		return at, 0, nil
+	}
+	fileIndent, searchIndent := "", ""
+	for i, line := range search {
+		if strings.TrimSpace(line) != "" {
+			fileIndent, searchIndent = indentOf(lines[at+i]), indentOf(line)
+			break
+		}
+	}
+	// Models often indent with spaces where the file uses tabs; map each
+	// level of the search indent onto one tab.
+	spacesPerTab := 0
+	if t := strings.Count(fileIndent, "\t"); t > 0 && t == len(fileIndent) &&
+		strings.Trim(searchIndent, " ") == "" && len(searchIndent)%t == 0 {
+		spacesPerTab = len(searchIndent) / t
+	}
+	return at, len(search), func(replace []string) []string {
+		out := make([]string, len(replace))
+		for i, line := range replace {
+			indent := indentOf(line)
+			switch {
+			case strings.TrimSpace(line) == "":
+				out[i] = line
+			case spacesPerTab > 0 && strings.Trim(indent, " ") == "":
+				out[i] = strings.Repeat("\t", len(indent)/spacesPerTab) + strings.Repeat(" ", len(indent)%spacesPerTab) + line[len(indent):]
+			case strings.HasPrefix(line, searchIndent):
+				out[i] = fileIndent + strings.TrimPrefix(line, searchIndent)
+			default:
+				out[i] = line
+			}
+		}
+		return out
+	}
+}
+
+// match returns where search occurs, notFoundAt when it does not, and
+// ambiguousAt when it occurs more than once.
+func match(lines, search []string, eq func(a, b string) bool) int {
+	found := notFoundAt
+	for at := 0; at+len(search) <= len(lines); at++ {
+		ok := true
+		for i, s := range search {
+			if !eq(lines[at+i], s) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		if found != notFoundAt {
+			return ambiguousAt
+		}
+		found = at
+	}
+	return found
+}
+
+func searchError(i, total int, search []string, problem string) error {
+	first := ""
+	for _, line := range search {
+		if strings.TrimSpace(line) != "" {
+			first = strings.TrimSpace(line)
+			break
+		}
+	}
+	which := "search text"
+	if total > 1 {
+		which = fmt.Sprintf("search text of edit %d/%d", i+1, total)
+	}
+	return fmt.Errorf("%s %s (first line %q)", which, problem, first)
+}
+
+func normalize(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
+
+func indentOf(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// trimBlank drops blank lines at either end.
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,174 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edits   []Edit
+		want    string
+		err     string // substring of the expected error
+	}{
+		{
+			name:    "exact",
+			content: "a\nb\nc\n",
+			edits:   []Edit{{Search: "b", Replace: "x\ny"}},
+			want:    "a\nx\ny\nc\n",
+		},
+		{
+			name:    "empty search appends",
+			content: "a\n",
+			edits:   []Edit{{Search: "", Replace: "b"}},
+			want:    "a\nb\n",
+		},
+		{
+			name:    "keeps a missing trailing newline",
+			content: "a\nb",
+			edits:   []Edit{{Search: "b", Replace: "c"}},
+			want:    "a\nc",
+		},
+		{
+			name:    "edits in turn",
+			content: "a\nb\nc\n",
+			edits:   []Edit{{Search: "a", Replace: "x"}, {Search: "c", Replace: "z"}},
+			want:    "x\nb\nz\n",
+		},
+		{
+			name:    "whitespace-insensitive match reindents",
+			content: "func f() {\n\tif x {\n\t\treturn\n\t}\n}\n",
+			edits:   []Edit{{Search: "    if x {\n        return\n    }", Replace: "    if x {\n        return nil\n    }"}},
+			want:    "func f() {\n\tif x {\n\t\treturn nil\n\t}\n}\n",
+		},
+		{
+			name:    "not found",
+			content: "a\nb\n",
+			edits:   []Edit{{Search: "z", Replace: "y"}},
+			err:     `not found in the file (first line "z")`,
+		},
+		{
+			name:    "ambiguous",
+			content: "x := 1\ny := 2\nx := 1\n",
+			edits:   []Edit{{Search: "x := 1", Replace: "x := 3"}},
+			err:     "matches more than once",
+		},
+		{
+			name:    "ambiguous after whitespace",
+			content: "\tx := 1\ny\n  x := 1\n",
+			edits:   []Edit{{Search: "x := 1", Replace: "x := 3"}},
+			err:     "matches more than once",
+		},
+		{
+			name:    "context makes it unique",
+			content: "x := 1\ny := 2\nx := 1\n",
+			edits:   []Edit{{Search: "y := 2\nx := 1", Replace: "y := 2\nx := 3"}},
+			want:    "x := 1\ny := 2\nx := 3\n",
+		},
+		{
+			name:    "names the failing edit",
+			content: "a\n",
+			edits:   []Edit{{Search: "a", Replace: "b"}, {Search: "a", Replace: "c"}},
+			err:     "edit 2/2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.content, tt.edits)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Apply error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnified(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  []Edit
+		err   bool
+	}{
+		{
+			name:  "one hunk",
+			patch: "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+			want:  []Edit{{Search: "a\nb\nc", Replace: "a\nx\nc"}},
+		},
+		{
+			name:  "blank context line",
+			patch: "@@ -1,3 +1,3 @@\n a\n\n-b\n+x\n",
+			want:  []Edit{{Search: "a\n\nb", Replace: "a\n\nx"}},
+		},
+		{
+			name:  "two files",
+			patch: "--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n--- a/g\n+++ b/g\n@@ -1 +1 @@\n-c\n+d\n",
+			want:  []Edit{{Search: "a", Replace: "b"}, {Search: "c", Replace: "d"}},
+		},
+		{
+			name:  "-- and ++ lines inside a counted hunk",
+			patch: "@@ -1,2 +1,2 @@\n-- x\n++ y\n keep\n",
+			want:  []Edit{{Search: "- x\nkeep", Replace: "+ y\nkeep"}},
+		},
+		{
+			name:  "-- and ++ lines inside a bare hunk",
+			patch: "@@ ... @@\n-- x\n++ y\n",
+			want:  []Edit{{Search: "- x", Replace: "+ y"}},
+		},
+		{
+			name:  "header after a bare hunk",
+			patch: "@@ ... @@\n-a\n+b\n--- a/g\n+++ b/g\n@@ ... @@\n-c\n+d\n",
+			want:  []Edit{{Search: "a", Replace: "b"}, {Search: "c", Replace: "d"}},
+		},
+		{
+			name:  "no newline marker",
+			patch: "@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n",
+			want:  []Edit{{Search: "a", Replace: "b"}},
+		},
+		{name: "no hunks", patch: "--- a/f\n+++ b/f\n", err: true},
+		{name: "stray line", patch: "@@ -1 +1 @@\n*a\n", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUnified(tt.patch)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParseUnified = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseUnified: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseUnified = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchReplace(t *testing.T) {
+	body := "intro\n<<<<<<< SEARCH\nold\n=======\nnew\n>>>>>>> REPLACE\n<<<<<<< SEARCH\n=======\nappended\n>>>>>>> REPLACE\n"
+	got, err := ParseSearchReplace(body)
+	if err != nil {
+		t.Fatalf("ParseSearchReplace: %v", err)
+	}
+	want := []Edit{{Search: "old", Replace: "new"}, {Search: "", Replace: "appended"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSearchReplace = %q, want %q", got, want)
+	}
+	if _, err := ParseSearchReplace("<<<<<<< SEARCH\nold\n=======\nnew\n"); err == nil {
+		t.Error("unclosed block: want an error")
+	}
+}
@@ -0,0 +1,54 @@
+// Package endpoint holds the per-profile connection settings shared by the
+// HTTP backends (base URL, credentials, extra headers).
+package endpoint
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Endpoint describes where and how to reach a backend. Empty fields fall back
+// to each backend's environment variables and defaults.
+type Endpoint struct {
+	BaseURL string
+	APIKey  string
+	Headers map[string]string
+}
+
+// Resolve picks the explicit base URL, then the env var, then def, and
+// normalizes it to a scheme-qualified URL without a trailing slash.
+func Resolve(explicit, envVar, def string) string {
+	base := strings.TrimSpace(explicit)
+	if base == "" && envVar != "" {
+		base = strings.TrimSpace(os.Getenv(envVar))
+	}
+	if base == "" {
+		base = def
+	}
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "http://" + base
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// Key returns the explicit API key, or the first non-empty env var.
+func Key(explicit string, envVars ...string) string {
+	if key := strings.TrimSpace(explicit); key != "" {
+		return key
+	}
+	for _, name := range envVars {
+		if key := strings.TrimSpace(os.Getenv(name)); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// ApplyHeaders sets the endpoint's extra headers on req. They are applied last
+// so a profile can override anything the backend set.
+func (e Endpoint) ApplyHeaders(req *http.Request) {
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+}
@@ -8,54 +8,132 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
+
+	"dwight/internal/endpoint"
 )
 
 const defaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
 
+// ChatMessage is one turn. Assistant turns may carry FunctionCalls; role
+// "tool" sends the result of the ToolName call as a functionResponse.
 type ChatMessage struct {
-	Role    string
-	Content string
+	Role          string
+	Content       string
+	FunctionCalls []FunctionCall
+	ToolName      string
+}
+
+// FunctionCall is a functionCall part from a model turn. Signature is the
+// part's thoughtSignature, which must be sent back unchanged.
+type FunctionCall struct {
+	Name      string
+	Args      map[string]any
+	Signature string
+}
+
+// FunctionDeclaration describes a tool in the request's functionDeclarations.
+type FunctionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
 }
 
 type ChatRequest struct {
+	Endpoint    endpoint.Endpoint
 	Model       string
 	Messages    []ChatMessage
 	System      string
+	Tools       []FunctionDeclaration
 	Temperature float64
-	Timeout     time.Duration
+	// Optional generationConfig fields; zero values are omitted.
+	TopP            float64
+	TopK            int
+	MaxOutputTokens int
+	StopSequences   []string
+	Seed            int
+	Timeout         time.Duration
 }
 
 type StreamChunk struct {
-	Content      string
-	Done         bool
-	Duration     time.Duration
-	PromptTokens int
-	TotalTokens  int
-	Err          error
+	Content       string
+	Done          bool
+	Duration      time.Duration
+	PromptTokens  int
+	TotalTokens   int
+	FunctionCalls []FunctionCall
+	Err           error
 }
 
-func APIKey() string {
-	if key := strings.TrimSpace(os.Getenv("GEMINI_API_KEY")); key != "" {
-		return key
-	}
-	return strings.TrimSpace(os.Getenv("GOOGLE_API_KEY"))
+// BaseURL returns the explicit API root, else GEMINI_BASE_URL, else the public API.
+func BaseURL(explicit string) string {
+	return endpoint.Resolve(explicit, "GEMINI_BASE_URL", defaultBaseURL)
 }
 
-func CheckModel(modelName string) error {
+// APIKey returns the explicit key, else GEMINI_API_KEY, else GOOGLE_API_KEY.
+func APIKey(explicit string) string {
+	return endpoint.Key(explicit, "GEMINI_API_KEY", "GOOGLE_API_KEY")
+}
+
+func CheckModel(ep endpoint.Endpoint, modelName string) error {
 	if strings.TrimSpace(modelName) == "" {
 		return fmt.Errorf("Gemini profile is missing a model name")
 	}
-	if APIKey() == "" {
+	if APIKey(ep.APIKey) == "" {
 		return fmt.Errorf("Gemini API key missing. Set GEMINI_API_KEY or GOOGLE_API_KEY, then retry")
 	}
 	return nil
 }
 
+// limitCache maps "baseURL|model" to the model's inputTokenLimit.
+var limitCache sync.Map
+
+// InputTokenLimit reads the model's inputTokenLimit from the models endpoint.
+func InputTokenLimit(ctx context.Context, ep endpoint.Endpoint, modelName string) (int, error) {
+	if err := CheckModel(ep, modelName); err != nil {
+		return 0, err
+	}
+	key := BaseURL(ep.BaseURL) + "|" + modelName
+	if n, ok := limitCache.Load(key); ok {
+		return n.(int), nil
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	url := fmt.Sprintf("%s/models/%s", BaseURL(ep.BaseURL), strings.TrimPrefix(modelName, "models/"))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %v", err)
+	}
+	httpReq.Header.Set("x-goog-api-key", APIKey(ep.APIKey))
+	ep.ApplyHeaders(httpReq)
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("Gemini API error: %d %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var info struct {
+		InputTokenLimit int `json:"inputTokenLimit"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if info.InputTokenLimit <= 0 {
+		return 0, fmt.Errorf("no inputTokenLimit reported for %s", modelName)
+	}
+	limitCache.Store(key, info.InputTokenLimit)
+	return info.InputTokenLimit, nil
+}
+
 func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
-	if err := CheckModel(req.Model); err != nil {
+	if err := CheckModel(req.Endpoint, req.Model); err != nil {
 		return nil, err
 	}
 
@@ -71,13 +149,14 @@ func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error
 		return nil, fmt.Errorf("failed to encode request: %v", err)
 	}
 
-	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", defaultBaseURL, req.Model)
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", BaseURL(req.Endpoint.BaseURL), req.Model)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %v", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-goog-api-key", APIKey())
+	httpReq.Header.Set("x-goog-api-key", APIKey(req.Endpoint.APIKey))
+	req.Endpoint.ApplyHeaders(httpReq)
 
 	resp, err := client.Do(httpReq)
 	if err != nil {
@@ -125,14 +204,16 @@ func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error
 			lastPromptTokens = respChunk.UsageMetadata.PromptTokenCount
 			lastTotalTokens = respChunk.UsageMetadata.TotalTokenCount
 			text := respChunk.text()
-			if text == "" {
+			calls := respChunk.functionCalls()
+			if text == "" && len(calls) == 0 {
 				continue
 			}
 			ch <- StreamChunk{
-				Content:      text,
-				Duration:     time.Since(startTime),
-				PromptTokens: lastPromptTokens,
-				TotalTokens:  lastTotalTokens,
+				Content:       text,
+				Duration:      time.Since(startTime),
+				PromptTokens:  lastPromptTokens,
+				TotalTokens:   lastTotalTokens,
+				FunctionCalls: calls,
 			}
 		}
 
@@ -158,23 +239,75 @@ func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error
 func buildPayload(req ChatRequest) map[string]interface{} {
 	contents := make([]map[string]interface{}, 0, len(req.Messages))
 	for _, msg := range req.Messages {
+		var parts []map[string]interface{}
 		role := "user"
-		if strings.EqualFold(msg.Role, "assistant") || strings.EqualFold(msg.Role, "model") {
+		switch {
+		case strings.EqualFold(msg.Role, "tool"):
+			parts = append(parts, map[string]interface{}{
+				"functionResponse": map[string]interface{}{
+					"name":     msg.ToolName,
+					"response": map[string]string{"content": msg.Content},
+				},
+			})
+			// Responses to one turn's calls belong together in a single content.
+			if n := len(contents); n > 0 && contents[n-1]["role"] == "user" && isFunctionResponse(contents[n-1]) {
+				contents[n-1]["parts"] = append(contents[n-1]["parts"].([]map[string]interface{}), parts...)
+				continue
+			}
+		case strings.EqualFold(msg.Role, "assistant") || strings.EqualFold(msg.Role, "model"):
 			role = "model"
+			if msg.Content != "" || len(msg.FunctionCalls) == 0 {
+				parts = append(parts, map[string]interface{}{"text": msg.Content})
+			}
+			for _, call := range msg.FunctionCalls {
+				args := call.Args
+				if args == nil {
+					args = map[string]any{}
+				}
+				part := map[string]interface{}{
+					"functionCall": map[string]interface{}{"name": call.Name, "args": args},
+				}
+				if call.Signature != "" {
+					part["thoughtSignature"] = call.Signature
+				}
+				parts = append(parts, part)
+			}
+		default:
+			parts = append(parts, map[string]interface{}{"text": msg.Content})
 		}
 		contents = append(contents, map[string]interface{}{
-			"role": role,
-			"parts": []map[string]string{
-				{"text": msg.Content},
-			},
+			"role":  role,
+			"parts": parts,
 		})
 	}
 
+	genConfig := map[string]interface{}{
+		"temperature": req.Temperature,
+	}
+	if req.TopP > 0 {
+		genConfig["topP"] = req.TopP
+	}
+	if req.TopK > 0 {
+		genConfig["topK"] = req.TopK
+	}
+	if req.MaxOutputTokens > 0 {
+		genConfig["maxOutputTokens"] = req.MaxOutputTokens
+	}
+	if len(req.StopSequences) > 0 {
+		genConfig["stopSequences"] = req.StopSequences
+	}
+	if req.Seed != 0 {
+		genConfig["seed"] = req.Seed
+	}
+
 	payload := map[string]interface{}{
-		"contents": contents,
-		"generationConfig": map[string]interface{}{
-			"temperature": req.Temperature,
-		},
+		"contents":         contents,
+		"generationConfig": genConfig,
+	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = []map[string]interface{}{
+			{"functionDeclarations": req.Tools},
+		}
 	}
 
 	if strings.TrimSpace(req.System) != "" {
@@ -192,7 +325,12 @@ type generateContentResponse struct {
 	Candidates []struct {
 		Content struct {
 			Parts []struct {
-				Text string `json:"text"`
+				Text         string `json:"text"`
+				FunctionCall *struct {
+					Name string         `json:"name"`
+					Args map[string]any `json:"args"`
+				} `json:"functionCall"`
+				ThoughtSignature string `json:"thoughtSignature"`
 			} `json:"parts"`
 		} `json:"content"`
 	} `json:"candidates"`
@@ -211,3 +349,28 @@ func (r generateContentResponse) text() string {
 	}
 	return b.String()
 }
+
+func (r generateContentResponse) functionCalls() []FunctionCall {
+	var calls []FunctionCall
+	for _, candidate := range r.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.FunctionCall != nil {
+				calls = append(calls, FunctionCall{
+					Name:      part.FunctionCall.Name,
+					Args:      part.FunctionCall.Args,
+					Signature: part.ThoughtSignature,
+				})
+			}
+		}
+	}
+	return calls
+}
+
+func isFunctionResponse(content map[string]interface{}) bool {
+	parts, _ := content["parts"].([]map[string]interface{})
+	if len(parts) == 0 {
+		return false
+	}
+	_, ok := parts[0]["functionResponse"]
+	return ok
+}
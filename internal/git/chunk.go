@@ -0,0 +1,79 @@
+package git
+
+import "strings"
+
+// Chunks splits a unified diff into pieces of at most max bytes. It breaks
+// between files first, then between the hunks of one file (repeating the file
+// header so each piece still names its file), and only cuts inside a hunk
+// when that hunk alone is too large. max <= 0 means no limit.
+func Chunks(diff string, max int) []string {
+	if strings.TrimSpace(diff) == "" {
+		return nil
+	}
+	if max <= 0 || len(diff) <= max {
+		return []string{diff}
+	}
+
+	var pieces []string
+	for _, file := range splitBefore(diff, "diff --git ") {
+		if len(file) <= max {
+			pieces = append(pieces, file)
+			continue
+		}
+		parts := splitBefore(file, "@@")
+		header, hunks := "", parts
+		if !strings.HasPrefix(parts[0], "@@") {
+			header, hunks = parts[0], parts[1:]
+		}
+		if len(header) >= max/2 {
+			header = ""
+		}
+		for _, h := range hunks {
+			unit := header + h
+			for len(unit) > max {
+				cut := strings.LastIndex(unit[:max], "\n") + 1
+				if cut <= len(header) {
+					cut = max
+				}
+				pieces = append(pieces, unit[:cut])
+				unit = header + unit[cut:]
+			}
+			pieces = append(pieces, unit)
+		}
+	}
+
+	var chunks []string
+	var cur strings.Builder
+	for _, p := range pieces {
+		if cur.Len() > 0 && cur.Len()+len(p) > max {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(p)
+	}
+	if cur.Len() > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
+
+// splitBefore splits s into pieces that each start with a line beginning
+// with prefix; text before the first such line is its own piece.
+func splitBefore(s, prefix string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); {
+		end := strings.IndexByte(s[i:], '\n')
+		if end < 0 {
+			end = len(s)
+		} else {
+			end += i + 1
+		}
+		if i > start && strings.HasPrefix(s[i:], prefix) {
+			parts = append(parts, s[start:i])
+			start = i
+		}
+		i = end
+	}
+	return append(parts, s[start:])
+}
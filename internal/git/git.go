@@ -0,0 +1,194 @@
+// Package git runs the git commands Dwight needs to show, stage and commit
+// the files it wrote, and to read diffs for commit messages and reviews.
+// Every call takes the repository root; paths are relative to it.
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// run executes git in root and returns stdout. A non-zero exit becomes an
+// error carrying git's own message.
+func run(root, stdin string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return stdout.String(), fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// Branch is the current branch name, or "" when HEAD is detached.
+func Branch(root string) string {
+	out, err := run(root, "", "symbolic-ref", "--short", "-q", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// hasHead reports whether the repository has at least one commit.
+func hasHead(root string) bool {
+	_, err := run(root, "", "rev-parse", "--verify", "-q", "HEAD")
+	return err == nil
+}
+
+// Status is `git status --short` limited to paths.
+func Status(root string, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", nil
+	}
+	return run(root, "", append([]string{"status", "--short", "--untracked-files=all", "--"}, paths...)...)
+}
+
+// Diff shows how paths differ from HEAD, staged or not. Untracked files are
+// shown as new files.
+func Diff(root string, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", nil
+	}
+	var out strings.Builder
+	if hasHead(root) {
+		d, err := run(root, "", append([]string{"diff", "HEAD", "--"}, paths...)...)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(d)
+	} else {
+		d, err := run(root, "", append([]string{"diff", "--cached", "--"}, paths...)...)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(d)
+	}
+
+	untracked, err := run(root, "", append([]string{"ls-files", "--others", "--exclude-standard", "-z", "--"}, paths...)...)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range strings.Split(untracked, "\x00") {
+		if p == "" {
+			continue
+		}
+		// --no-index exits 1 when the files differ, which they always do here.
+		d, err := run(root, "", "diff", "--no-index", "--", "/dev/null", p)
+		if err != nil && d == "" {
+			return "", err
+		}
+		out.WriteString(d)
+	}
+	return out.String(), nil
+}
+
+// StagedDiff is `git diff --cached`: what the next commit would contain.
+func StagedDiff(root string) (string, error) {
+	return run(root, "", "diff", "--cached")
+}
+
+// DiffRange is `git diff <spec>` for a ref or range such as main..HEAD.
+func DiffRange(root, spec string) (string, error) {
+	if strings.HasPrefix(spec, "-") {
+		return "", fmt.Errorf("invalid range %q", spec)
+	}
+	return run(root, "", "diff", spec, "--")
+}
+
+// HooksDir is where git looks for this repository's hooks.
+func HooksDir(root string) (string, error) {
+	out, err := run(root, "", "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(out)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir, nil
+}
+
+// Staged lists the paths with staged changes.
+func Staged(root string) ([]string, error) {
+	out, err := run(root, "", "diff", "--cached", "--name-only", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range strings.Split(out, "\x00") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+// Add stages paths, including deletions.
+func Add(root string, paths []string) error {
+	if len(paths) == 0 {
+		return errors.New("nothing to stage")
+	}
+	_, err := run(root, "", append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+// Commit commits what is staged with message and returns the short hash.
+func Commit(root, message string) (string, error) {
+	if strings.TrimSpace(message) == "" {
+		return "", errors.New("empty commit message")
+	}
+	if _, err := run(root, message, "commit", "-q", "-F", "-"); err != nil {
+		return "", err
+	}
+	out, err := run(root, "", "rev-parse", "--short", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+// WithTrailer appends a "key: value" trailer to message, separated from the
+// body by a blank line unless the message already ends in trailers. A final
+// paragraph only counts as trailers when it names a key like Signed-off-by
+// or key itself, so a body ending in "Note: ..." keeps its blank line.
+func WithTrailer(message, key, value string) string {
+	message = strings.TrimRight(message, "\n")
+	if value == "" {
+		return message + "\n"
+	}
+	trailer := key + ": " + value
+	paragraphs := strings.Split(message, "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	if len(paragraphs) > 1 && isTrailerBlock(last, key) {
+		return message + "\n" + trailer + "\n"
+	}
+	return message + "\n\n" + trailer + "\n"
+}
+
+// knownTrailers are keys that mark a paragraph as trailers, besides any
+// "*-by" key and the key being added.
+var knownTrailers = []string{"fixes", "closes", "refs", "change-id", "bug", "cc"}
+
+func isTrailerBlock(paragraph, key string) bool {
+	known := false
+	for _, line := range strings.Split(paragraph, "\n") {
+		k, _, ok := strings.Cut(line, ": ")
+		if !ok || k == "" || strings.Trim(k, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") != "" {
+			return false
+		}
+		k = strings.ToLower(k)
+		if k == strings.ToLower(key) || strings.HasSuffix(k, "-by") || slices.Contains(knownTrailers, k) {
+			known = true
+		}
+	}
+	return known
+}
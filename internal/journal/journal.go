@@ -0,0 +1,264 @@
+// Package journal records every file Dwight writes during a session, with
+// what was there before, so any write can be undone. Each session's journal
+// is kept under the data directory as journal/<id>.json.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"dwight/internal/storage"
+)
+
+// ErrModified means a file changed on disk after Dwight wrote it, so
+// reverting would discard someone else's edits.
+var ErrModified = errors.New("changed on disk since it was written")
+
+// Change is one write.
+type Change struct {
+	Path           string    `json:"path"` // absolute
+	Existed        bool      `json:"existed"`
+	Original       string    `json:"original,omitempty"`
+	Content        string    `json:"content"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Time           time.Time `json:"time"`
+	Reverted       bool      `json:"reverted,omitempty"`
+}
+
+// Journal is the list of writes made in one session, oldest first.
+type Journal struct {
+	ID      string    `json:"id"`
+	Started time.Time `json:"started"`
+	Changes []Change  `json:"changes"`
+}
+
+// File summarizes the writes to one path.
+type File struct {
+	Path     string
+	Writes   int
+	Created  bool   // did not exist before the session's first write
+	Reverted bool   // every write has been reverted
+	Original string // content before the first write
+	Content  string // content after the latest write
+	Last     time.Time
+}
+
+func New() *Journal {
+	now := time.Now()
+	return &Journal{ID: now.Format("20060102-150405"), Started: now}
+}
+
+// Dir is where session journals are saved.
+func Dir() string {
+	return filepath.Join(storage.DataDir(), "journal")
+}
+
+// Capture reads path's current state as the original of a write about to happen.
+func Capture(path string) (Change, error) {
+	c := Change{Path: path, Time: time.Now()}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		c.Existed, c.Original = true, string(data)
+	case !os.IsNotExist(err):
+		return c, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Write writes content to path, creating directories, and records the change.
+func (j *Journal) Write(path, content, conversationID string) error {
+	c, err := Capture(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+	c.Content, c.ConversationID = content, conversationID
+	j.Record(c)
+	return nil
+}
+
+// WriteAll writes several files as one unit: if any write fails, the files
+// already written are put back and directories it created are removed, and
+// nothing is recorded.
+func (j *Journal) WriteAll(files map[string]string, conversationID string) error {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	changes := make([]Change, 0, len(paths))
+	for _, p := range paths {
+		c, err := Capture(p)
+		if err != nil {
+			return err
+		}
+		c.Content, c.ConversationID = files[p], conversationID
+		changes = append(changes, c)
+	}
+
+	var createdDirs []string
+	for i, c := range changes {
+		missing := missingDirs(filepath.Dir(c.Path))
+		err := os.MkdirAll(filepath.Dir(c.Path), 0755)
+		createdDirs = append(createdDirs, missing...)
+		if err == nil {
+			err = os.WriteFile(c.Path, []byte(c.Content), 0644)
+		}
+		if err != nil {
+			rollback(changes[:i+1], createdDirs)
+			return fmt.Errorf("failed to write %s: %v (no files were changed)", c.Path, err)
+		}
+	}
+	for _, c := range changes {
+		j.Changes = append(j.Changes, c)
+	}
+	j.save()
+	return nil
+}
+
+// missingDirs lists dir and those of its parents that do not exist, deepest first.
+func missingDirs(dir string) []string {
+	var out []string
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return out
+		}
+		out = append(out, dir)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return out
+		}
+		dir = parent
+	}
+}
+
+// rollback restores the originals of changes and removes created
+// directories, deepest first, if they are empty again.
+func rollback(changes []Change, createdDirs []string) {
+	for _, c := range changes {
+		restore(c.Path, "", c.Existed, c.Original, true)
+	}
+	sort.Slice(createdDirs, func(a, b int) bool { return len(createdDirs[a]) > len(createdDirs[b]) })
+	for _, dir := range createdDirs {
+		os.Remove(dir)
+	}
+}
+
+// Record adds a change made elsewhere (e.g. by a tool) and saves the journal.
+func (j *Journal) Record(c Change) {
+	if c.Time.IsZero() {
+		c.Time = time.Now()
+	}
+	j.Changes = append(j.Changes, c)
+	j.save()
+}
+
+// Undo reverts the most recent write that is not yet reverted and returns its path.
+func (j *Journal) Undo(force bool) (string, error) {
+	for i := len(j.Changes) - 1; i >= 0; i-- {
+		if j.Changes[i].Reverted {
+			continue
+		}
+		c := j.Changes[i]
+		if err := restore(c.Path, c.Content, c.Existed, c.Original, force); err != nil {
+			return c.Path, err
+		}
+		j.Changes[i].Reverted = true
+		j.save()
+		return c.Path, nil
+	}
+	return "", fmt.Errorf("nothing to undo")
+}
+
+// RevertFile puts path back the way it was before the session wrote it.
+func (j *Journal) RevertFile(path string, force bool) error {
+	var live []int
+	for i, c := range j.Changes {
+		if c.Path == path && !c.Reverted {
+			live = append(live, i)
+		}
+	}
+	if len(live) == 0 {
+		return fmt.Errorf("no changes to revert")
+	}
+	first, last := j.Changes[live[0]], j.Changes[live[len(live)-1]]
+	if err := restore(path, last.Content, first.Existed, first.Original, force); err != nil {
+		return err
+	}
+	for _, i := range live {
+		j.Changes[i].Reverted = true
+	}
+	j.save()
+	return nil
+}
+
+// Files lists the paths written this session in the order first touched.
+func (j *Journal) Files() []File {
+	var files []File
+	index := map[string]int{}
+	for _, c := range j.Changes {
+		i, ok := index[c.Path]
+		if !ok {
+			i = len(files)
+			index[c.Path] = i
+			files = append(files, File{Path: c.Path, Created: !c.Existed, Original: c.Original, Reverted: true})
+		}
+		f := &files[i]
+		f.Writes++
+		f.Content, f.Last = c.Content, c.Time
+		if !c.Reverted {
+			f.Reverted = false
+		}
+	}
+	return files
+}
+
+// Pending counts the writes not yet reverted.
+func (j *Journal) Pending() int {
+	n := 0
+	for _, c := range j.Changes {
+		if !c.Reverted {
+			n++
+		}
+	}
+	return n
+}
+
+// restore checks path still holds expected, then writes original back or
+// removes the file if it did not exist.
+func restore(path, expected string, existed bool, original string, force bool) error {
+	if !force {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err != nil || string(data) != expected {
+			return ErrModified
+		}
+	}
+	if !existed {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, []byte(original), 0644)
+}
+
+func (j *Journal) save() {
+	os.MkdirAll(Dir(), 0755)
+	data, _ := json.MarshalIndent(j, "", "  ")
+	os.WriteFile(filepath.Join(Dir(), j.ID+".json"), data, 0644)
+}
@@ -8,21 +8,33 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
+
+	"dwight/internal/endpoint"
 )
 
-// GetURL reads OLLAMA_HOST env var, falls back to localhost:11434.
-func GetURL() string {
-	host := os.Getenv("OLLAMA_HOST")
-	if host == "" {
-		return "http://localhost:11434"
+// BaseURL returns the explicit host, else OLLAMA_HOST, else localhost:11434.
+func BaseURL(explicit string) string {
+	return endpoint.Resolve(explicit, "OLLAMA_HOST", "http://localhost:11434")
+}
+
+// newRequest builds a request against ep. Ollama itself has no auth, but a
+// key is sent as a bearer token for reverse proxies that require one.
+func newRequest(ctx context.Context, ep endpoint.Endpoint, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, BaseURL(ep.BaseURL)+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
-		host = "http://" + host
+	if key := strings.TrimSpace(ep.APIKey); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
 	}
-	return host
+	ep.ApplyHeaders(req)
+	return req, nil
 }
 
 // Model represents a locally installed Ollama model.
@@ -32,21 +44,61 @@ type Model struct {
 	Size       int64  `json:"size"`
 }
 
-// ChatMessage is a single message in a conversation.
+// ChatMessage is a single message in a conversation. Tool results use role
+// "tool" and name the function in ToolName.
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+// ToolCall is a function call in an assistant message.
+type ToolCall struct {
+	Function struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"function"`
+}
+
+// Tool declares a function the model may call.
+type Tool struct {
+	Type     string       `json:"type"` // always "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is a tool's name, description and JSON Schema parameters.
+type ToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
 }
 
 // ChatRequest configures a chat API call.
 type ChatRequest struct {
+	Endpoint    endpoint.Endpoint
 	Model       string
 	Messages    []ChatMessage
+	Tools       []Tool
 	Temperature float64
+	Options     Options
+	KeepAlive   string // e.g. "10m"; empty keeps the server default
 	Stream      bool
 	Timeout     time.Duration
 }
 
+// Options are Ollama model parameters sent in the request's "options" object.
+// Zero values are omitted so the model's Modelfile defaults apply.
+type Options struct {
+	NumCtx        int
+	TopP          float64
+	TopK          int
+	RepeatPenalty float64
+	Seed          int
+	Stop          []string
+	NumPredict    int
+}
+
 // ChatResponse holds the result of a non-streaming chat call.
 type ChatResponse struct {
 	Content      string
@@ -62,13 +114,18 @@ type StreamChunk struct {
 	Duration     time.Duration
 	PromptTokens int
 	TotalTokens  int
+	ToolCalls    []ToolCall
 	Err          error
 }
 
 // CheckModel returns true if the named model is locally available.
-func CheckModel(modelName string) (bool, error) {
+func CheckModel(ep endpoint.Endpoint, modelName string) (bool, error) {
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(GetURL() + "/api/tags")
+	req, err := newRequest(context.Background(), ep, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return false, fmt.Errorf("request failed: %v", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return false, fmt.Errorf("failed to connect to Ollama: %v", err)
 	}
@@ -96,9 +153,13 @@ func CheckModel(modelName string) (bool, error) {
 }
 
 // ListModels returns all locally installed models.
-func ListModels() ([]Model, error) {
+func ListModels(ep endpoint.Endpoint) ([]Model, error) {
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(GetURL() + "/api/tags")
+	req, err := newRequest(context.Background(), ep, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Ollama: %v", err)
 	}
@@ -118,13 +179,17 @@ func ListModels() ([]Model, error) {
 }
 
 // PullModel downloads a model. Blocks until complete.
-func PullModel(modelName string) error {
+func PullModel(ep endpoint.Endpoint, modelName string) error {
 	client := &http.Client{Timeout: 10 * time.Minute}
 
 	pullReq := map[string]string{"name": modelName}
 	jsonData, _ := json.Marshal(pullReq)
 
-	resp, err := client.Post(GetURL()+"/api/pull", "application/json", bytes.NewBuffer(jsonData))
+	req, err := newRequest(context.Background(), ep, http.MethodPost, "/api/pull", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("pull failed: %v", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("pull failed: %v", err)
 	}
@@ -160,24 +225,12 @@ func Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
 	}
 	client := &http.Client{Timeout: timeout}
 
-	messages := make([]map[string]string, len(req.Messages))
-	for i, m := range req.Messages {
-		messages[i] = map[string]string{"role": m.Role, "content": m.Content}
-	}
+	jsonData, _ := json.Marshal(buildPayload(req, false))
 
-	body := map[string]interface{}{
-		"model":       req.Model,
-		"messages":    messages,
-		"temperature": req.Temperature,
-		"stream":      false,
-	}
-	jsonData, _ := json.Marshal(body)
-
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, GetURL()+"/api/chat", bytes.NewBuffer(jsonData))
+	httpReq, err := newRequest(ctx, req.Endpoint, http.MethodPost, "/api/chat", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %v", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %v", err)
@@ -221,32 +274,20 @@ func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error
 	}
 	client := &http.Client{Timeout: timeout}
 
-	messages := make([]map[string]string, len(req.Messages))
-	for i, m := range req.Messages {
-		messages[i] = map[string]string{"role": m.Role, "content": m.Content}
-	}
-
-	body := map[string]interface{}{
-		"model":       req.Model,
-		"messages":    messages,
-		"temperature": req.Temperature,
-		"stream":      true,
-	}
-	jsonData, _ := json.Marshal(body)
+	jsonData, _ := json.Marshal(buildPayload(req, true))
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, GetURL()+"/api/chat", bytes.NewBuffer(jsonData))
+	httpReq, err := newRequest(ctx, req.Endpoint, http.MethodPost, "/api/chat", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %v", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %v", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("API error: %d", resp.StatusCode)
+		defer resp.Body.Close()
+		return nil, apiError(resp)
 	}
 
 	ch := make(chan StreamChunk)
@@ -264,7 +305,8 @@ func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error
 
 			var streamResp struct {
 				Message struct {
-					Content string `json:"content"`
+					Content   string     `json:"content"`
+					ToolCalls []ToolCall `json:"tool_calls"`
 				} `json:"message"`
 				Done            bool `json:"done"`
 				PromptEvalCount int  `json:"prompt_eval_count"`
@@ -275,8 +317,9 @@ func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error
 			}
 
 			chunk := StreamChunk{
-				Content: streamResp.Message.Content,
-				Done:    streamResp.Done,
+				Content:   streamResp.Message.Content,
+				Done:      streamResp.Done,
+				ToolCalls: streamResp.Message.ToolCalls,
 			}
 			if streamResp.Done {
 				chunk.Duration = time.Since(startTime)
@@ -294,6 +337,62 @@ func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error
 	return ch, nil
 }
 
+// buildPayload assembles an /api/chat body. Sampling parameters belong in
+// "options" — Ollama silently ignores them at the top level.
+func buildPayload(req ChatRequest, stream bool) map[string]interface{} {
+
+	opts := map[string]interface{}{"temperature": req.Temperature}
+	o := req.Options
+	if o.NumCtx > 0 {
+		opts["num_ctx"] = o.NumCtx
+	}
+	if o.TopP > 0 {
+		opts["top_p"] = o.TopP
+	}
+	if o.TopK > 0 {
+		opts["top_k"] = o.TopK
+	}
+	if o.RepeatPenalty > 0 {
+		opts["repeat_penalty"] = o.RepeatPenalty
+	}
+	if o.Seed != 0 {
+		opts["seed"] = o.Seed
+	}
+	if len(o.Stop) > 0 {
+		opts["stop"] = o.Stop
+	}
+	if o.NumPredict != 0 {
+		opts["num_predict"] = o.NumPredict
+	}
+
+	body := map[string]interface{}{
+		"model":    req.Model,
+		"messages": req.Messages,
+		"options":  opts,
+		"stream":   stream,
+	}
+	if len(req.Tools) > 0 {
+		body["tools"] = req.Tools
+	}
+	if req.KeepAlive != "" {
+		body["keep_alive"] = req.KeepAlive
+	}
+	return body
+}
+
+// apiError reports a non-200 response, including Ollama's error message
+// (e.g. "model does not support tools") when the body carries one.
+func apiError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		return fmt.Errorf("API error: %d %s", resp.StatusCode, body.Error)
+	}
+	return fmt.Errorf("API error: %d", resp.StatusCode)
+}
+
 // PopularModels returns a curated list of popular models for the library browser.
 type LibraryModel struct {
 	Name        string
@@ -318,17 +417,68 @@ func PopularModels() []LibraryModel {
 	}
 }
 
-// IsModelInstalled checks if a model name matches any installed model.
-func IsModelInstalled(name string, installed []Model) bool {
-	for _, m := range installed {
-		if m.Name == name {
-			return true
+// contextCache maps "baseURL|model" to the context length /api/show reported.
+var contextCache sync.Map
+
+// ContextLength asks /api/show for the model's context window. A num_ctx set
+// in the Modelfile wins over the architecture's context_length since that is
+// what the server actually allocates. Results are cached per server and model.
+func ContextLength(ctx context.Context, ep endpoint.Endpoint, modelName string) (int, error) {
+	key := BaseURL(ep.BaseURL) + "|" + modelName
+	if n, ok := contextCache.Load(key); ok {
+		return n.(int), nil
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	jsonData, _ := json.Marshal(map[string]string{"model": modelName})
+	req, err := newRequest(ctx, ep, http.MethodPost, "/api/show", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to Ollama: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("Ollama API error: %d", resp.StatusCode)
+	}
+
+	var show struct {
+		Parameters string                 `json:"parameters"`
+		ModelInfo  map[string]interface{} `json:"model_info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&show); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	n := 0
+	for _, line := range strings.Split(show.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			fmt.Sscanf(fields[1], "%d", &n)
 		}
 	}
-	return false
+	if n == 0 {
+		for k, v := range show.ModelInfo {
+			if !strings.HasSuffix(k, ".context_length") {
+				continue
+			}
+			if f, ok := v.(float64); ok {
+				n = int(f)
+			}
+		}
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("no context length reported for %s", modelName)
+	}
+	contextCache.Store(key, n)
+	return n, nil
 }
 
-// ContextWindowSize returns estimated context window for a model.
+// ContextWindowSize returns estimated context window for a model. It is only a
+// fallback for when /api/show cannot answer.
 func ContextWindowSize(modelName string) int {
 	switch {
 	case strings.Contains(modelName, "llama3.2"), strings.Contains(modelName, "llama3.1"):
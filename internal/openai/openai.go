@@ -0,0 +1,321 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"dwight/internal/endpoint"
+)
+
+// defaultBaseURL matches llama.cpp's `server` defaults; vLLM, LM Studio and
+// LocalAI only differ by port.
+const defaultBaseURL = "http://localhost:8080/v1"
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	Endpoint    endpoint.Endpoint
+	Model       string
+	Messages    []ChatMessage
+	Temperature float64
+	// Optional sampling fields; zero values are omitted. TopK and
+	// RepeatPenalty are llama.cpp/vLLM extensions to the OpenAI schema.
+	TopP          float64
+	TopK          int
+	RepeatPenalty float64
+	MaxTokens     int
+	Stop          []string
+	Seed          int
+	Timeout       time.Duration
+}
+
+type StreamChunk struct {
+	Content      string
+	Done         bool
+	Duration     time.Duration
+	PromptTokens int
+	TotalTokens  int
+	Err          error
+}
+
+// Model is an entry from GET /v1/models. MaxModelLen (vLLM) and
+// Meta.NCtxTrain (llama.cpp) are server extensions; zero when absent.
+type Model struct {
+	ID          string `json:"id"`
+	Created     int64  `json:"created"`
+	OwnedBy     string `json:"owned_by"`
+	MaxModelLen int    `json:"max_model_len"`
+	Meta        struct {
+		NCtxTrain int `json:"n_ctx_train"`
+	} `json:"meta"`
+}
+
+// ContextLength returns the context window the server advertises for
+// modelName, or 0 when it does not say.
+func (m Model) ContextLength() int {
+	if m.MaxModelLen > 0 {
+		return m.MaxModelLen
+	}
+	return m.Meta.NCtxTrain
+}
+
+// contextCache maps "baseURL|model" to the context length /models reported.
+var contextCache sync.Map
+
+// ContextWindow returns the context length the server advertises for
+// modelName, or 0 when it does not say. Models are matched as CheckModel
+// matches them. Known lengths are cached per server and model; an unknown one
+// is asked for again next time, as the model may still be loading.
+func ContextWindow(ctx context.Context, ep endpoint.Endpoint, modelName string) (int, error) {
+	key := BaseURL(ep.BaseURL) + "|" + modelName
+	if n, ok := contextCache.Load(key); ok {
+		return n.(int), nil
+	}
+	models, err := ListModels(ctx, ep)
+	if err != nil {
+		return 0, err
+	}
+	m, ok := findModel(models, modelName)
+	if !ok || m.ContextLength() == 0 {
+		return 0, nil
+	}
+	contextCache.Store(key, m.ContextLength())
+	return m.ContextLength(), nil
+}
+
+// BaseURL resolves the API root (including /v1). An explicit value wins, then
+// OPENAI_BASE_URL, then the llama.cpp default.
+func BaseURL(explicit string) string {
+	return endpoint.Resolve(explicit, "OPENAI_BASE_URL", defaultBaseURL)
+}
+
+// APIKey resolves the bearer token. Local servers usually need none.
+func APIKey(explicit string) string {
+	return endpoint.Key(explicit, "OPENAI_API_KEY")
+}
+
+func newRequest(ctx context.Context, ep endpoint.Endpoint, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, BaseURL(ep.BaseURL)+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key := APIKey(ep.APIKey); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	ep.ApplyHeaders(req)
+	return req, nil
+}
+
+// ListModels returns the models the server advertises.
+func ListModels(ctx context.Context, ep endpoint.Endpoint) ([]Model, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := newRequest(ctx, ep, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", BaseURL(ep.BaseURL), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OpenAI-compatible API error: %d %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result struct {
+		Data []Model `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return result.Data, nil
+}
+
+// CheckModel reports whether the server serves modelName. Servers that host a
+// single model (llama.cpp) accept any name, so a one-entry list always matches.
+func CheckModel(ctx context.Context, ep endpoint.Endpoint, modelName string) (bool, error) {
+	if strings.TrimSpace(modelName) == "" {
+		return false, fmt.Errorf("OpenAI-compatible profile is missing a model name")
+	}
+	models, err := ListModels(ctx, ep)
+	if err != nil {
+		return false, err
+	}
+	_, ok := findModel(models, modelName)
+	return ok, nil
+}
+
+// findModel picks modelName from models: the only entry of a one-model list,
+// else an exact ID or one ending in "/"+modelName.
+func findModel(models []Model, modelName string) (Model, bool) {
+	if len(models) == 1 {
+		return models[0], true
+	}
+	for _, m := range models {
+		if m.ID == modelName || strings.HasSuffix(m.ID, "/"+modelName) {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+// ChatStream posts to /chat/completions and streams the SSE response.
+func ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = 180 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	payload := map[string]interface{}{
+		"model":          req.Model,
+		"messages":       req.Messages,
+		"temperature":    req.Temperature,
+		"stream":         true,
+		"stream_options": map[string]bool{"include_usage": true},
+	}
+	if req.TopP > 0 {
+		payload["top_p"] = req.TopP
+	}
+	if req.TopK > 0 {
+		payload["top_k"] = req.TopK
+	}
+	if req.RepeatPenalty > 0 {
+		payload["repeat_penalty"] = req.RepeatPenalty
+	}
+	if req.MaxTokens > 0 {
+		payload["max_tokens"] = req.MaxTokens
+	}
+	if len(req.Stop) > 0 {
+		payload["stop"] = req.Stop
+	}
+	if req.Seed != 0 {
+		payload["seed"] = req.Seed
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", err)
+	}
+
+	httpReq, err := newRequest(ctx, req.Endpoint, http.MethodPost, "/chat/completions", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OpenAI-compatible API error: %d %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	ch := make(chan StreamChunk)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ch)
+
+		startTime := time.Now()
+		var promptTokens, totalTokens int
+		scanner := bufio.NewScanner(resp.Body)
+		const maxScanToken = 1024 * 1024
+		buf := make([]byte, 0, 64*1024)
+		scanner.Buffer(buf, maxScanToken)
+
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, ":") || !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "" {
+				continue
+			}
+			if data == "[DONE]" {
+				break
+			}
+
+			var chunk completionChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				ch <- StreamChunk{Err: fmt.Errorf("failed to parse stream: %v", err)}
+				return
+			}
+			if chunk.Error != nil && chunk.Error.Message != "" {
+				ch <- StreamChunk{Err: fmt.Errorf("OpenAI-compatible API error: %s", chunk.Error.Message)}
+				return
+			}
+			if chunk.Usage != nil {
+				promptTokens = chunk.Usage.PromptTokens
+				totalTokens = chunk.Usage.TotalTokens
+				if totalTokens == 0 {
+					totalTokens = chunk.Usage.PromptTokens + chunk.Usage.CompletionTokens
+				}
+			}
+			text := chunk.text()
+			if text == "" {
+				continue
+			}
+			ch <- StreamChunk{Content: text}
+		}
+
+		if err := scanner.Err(); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			ch <- StreamChunk{Err: fmt.Errorf("stream error: %v", err)}
+			return
+		}
+
+		ch <- StreamChunk{
+			Done:         true,
+			Duration:     time.Since(startTime),
+			PromptTokens: promptTokens,
+			TotalTokens:  totalTokens,
+		}
+	}()
+
+	return ch, nil
+}
+
+type completionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (c completionChunk) text() string {
+	var b strings.Builder
+	for _, choice := range c.Choices {
+		b.WriteString(choice.Delta.Content)
+	}
+	return b.String()
+}
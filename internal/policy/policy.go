@@ -0,0 +1,180 @@
+// Package policy decides where model-proposed writes may land. Paths are
+// resolved through symlinks and must stay inside the project; a deny-list
+// keeps git internals, dotfiles and keys off limits even there.
+package policy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Policy is the set of allowed roots and denied patterns.
+type Policy struct {
+	Base  string   // relative paths are joined onto it
+	Roots []string // a write must land inside one of these
+	Deny  []string // glob patterns relative to the matching root
+	Limit string   // when set, a write must also land inside it
+}
+
+// Target is a write that passed the policy.
+type Target struct {
+	Path    string // absolute, symlinks resolved
+	Rel     string // relative to the project root, slash-separated
+	NewDir  string // first directory the write would create, relative; empty if none
+	Ignored bool   // matched by .gitignore
+}
+
+// New builds a policy for a working directory and optional git root.
+func New(base, gitRoot string, deny []string) Policy {
+	p := Policy{Base: base, Deny: deny}
+	// The git root contains the working directory, so it goes first and
+	// deny patterns are matched against the whole repository.
+	for _, root := range []string{gitRoot, base} {
+		if root == "" {
+			continue
+		}
+		if resolved, err := Resolve(root); err == nil {
+			root = resolved
+		}
+		p.Roots = append(p.Roots, root)
+	}
+	return p
+}
+
+// Within narrows p to writes inside dir, keeping the roots deny patterns are
+// matched against.
+func (p Policy) Within(dir string) Policy {
+	if resolved, err := Resolve(dir); err == nil {
+		dir = resolved
+	}
+	p.Limit = dir
+	return p
+}
+
+// Check resolves target and reports why it may not be written, if so.
+func (p Policy) Check(target string) (Target, error) {
+	if strings.TrimSpace(target) == "" {
+		return Target{}, fmt.Errorf("no path given")
+	}
+	abs := target
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(p.Base, target)
+	}
+	resolved, err := Resolve(abs)
+	if err != nil {
+		return Target{}, err
+	}
+	if info, err := os.Stat(resolved); err == nil && info.IsDir() {
+		return Target{}, fmt.Errorf("%s is a directory", target)
+	}
+
+	root := ""
+	for _, r := range p.Roots {
+		if within(r, resolved) {
+			root = r
+			break
+		}
+	}
+	if root == "" {
+		return Target{}, fmt.Errorf("%s is outside the project", target)
+	}
+	if p.Limit != "" && !within(p.Limit, resolved) {
+		return Target{}, fmt.Errorf("%s is outside the working directory", target)
+	}
+	rel, _ := filepath.Rel(root, resolved)
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range p.Deny {
+		if Match(pattern, rel) {
+			return Target{}, fmt.Errorf("%s matches the write deny-list (%s)", rel, pattern)
+		}
+	}
+
+	t := Target{Path: resolved, Rel: rel}
+	for dir := filepath.Dir(resolved); within(root, dir) && dir != root; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		t.NewDir, _ = filepath.Rel(root, dir)
+	}
+	t.Ignored = gitIgnored(root, rel)
+	return t, nil
+}
+
+// Resolve evaluates symlinks in the longest existing prefix of p, so a path
+// that does not exist yet still resolves through a linked parent directory.
+// A dangling symlink resolves to where writing through it would land.
+func Resolve(p string) (string, error) {
+	p = filepath.Clean(p)
+	var rest []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		if link, err := os.Readlink(p); err == nil {
+			if !filepath.IsAbs(link) {
+				link = filepath.Join(filepath.Dir(p), link)
+			}
+			return Resolve(filepath.Join(append([]string{link}, rest...)...))
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(append([]string{p}, rest...)...), nil
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+		p = parent
+	}
+}
+
+func within(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// Match reports whether rel (slash-separated) matches pattern. A pattern
+// without a slash matches the file name; otherwise it matches the whole path,
+// with "**" standing for any number of directories.
+func Match(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "/")
+	if pattern == "" {
+		return false
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// gitIgnored asks git whether rel is ignored in the repository at root.
+func gitIgnored(root, rel string) bool {
+	cmd := exec.Command("git", "check-ignore", "-q", "--", rel)
+	cmd.Dir = root
+	return cmd.Run() == nil
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{".*", ".env", true},
+		{".*", "config/.env", true},
+		{".*", "src/main.go", false},
+		{"*.pem", "certs/server.pem", true},
+		{"id_rsa*", "home/id_rsa.pub", true},
+		{".git/**", ".git/config", true},
+		{".git/**", ".git/refs/heads/main", true},
+		{".git/**", "src/.git/config", false},
+		{"**/.*/**", ".github/workflows/ci.yml", true},
+		{"**/.*/**", ".vscode/tasks.json", true},
+		{"**/.*/**", "web/.cache/x/y", true},
+		{"**/.*/**", "src/main.go", false},
+		{"**/.*/**", ".env", true}, // a trailing "**" may be empty
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"/docs/*.md", "docs/a.md", true},
+		{"docs/**/*.md", "docs/a.md", true},
+		{"docs/**/*.md", "docs/x/y/a.md", true},
+		{"", "anything", false},
+		{"  ", "anything", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{"src", "sub", ".github/workflows"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	deny := []string{".git/**", ".*", "**/.*/**", "*.pem"}
+	p := New(filepath.Join(root, "sub"), root, deny)
+
+	tests := []struct {
+		name   string
+		policy Policy
+		target string
+		rel    string // expected Target.Rel when allowed
+		newDir string
+		err    string // substring of the expected error
+	}{
+		{name: "relative to base", policy: p, target: "main.go", rel: "sub/main.go"},
+		{name: "elsewhere in the repository", policy: p, target: "../src/a.go", rel: "src/a.go"},
+		{name: "creates directories", policy: p, target: "pkg/x/a.go", rel: "sub/pkg/x/a.go", newDir: "sub/pkg"},
+		{name: "absolute inside", policy: p, target: filepath.Join(root, "src", "b.go"), rel: "src/b.go"},
+		{name: "outside", policy: p, target: "../../elsewhere.go", err: "outside the project"},
+		{name: "through a symlink", policy: p, target: "../escape/a.go", err: "outside the project"},
+		{name: "dotfile", policy: p, target: "../.env", err: "deny-list (.*)"},
+		{name: "dot directory", policy: p, target: "../.github/workflows/ci.yml", err: "deny-list (**/.*/**)"},
+		{name: "git internals", policy: p, target: "../.git/config", err: "deny-list"},
+		{name: "key", policy: p, target: "server.pem", err: "deny-list (*.pem)"},
+		{name: "directory", policy: p, target: "../src", err: "is a directory"},
+		{name: "empty", policy: p, target: " ", err: "no path given"},
+		{name: "within the working directory", policy: p.Within(filepath.Join(root, "sub")), target: "main.go", rel: "sub/main.go"},
+		{name: "outside the working directory", policy: p.Within(filepath.Join(root, "sub")), target: "../src/a.go", err: "outside the working directory"},
+		{name: "no deny-list", policy: New(root, "", nil), target: ".env", rel: ".env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Check(tt.target)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Check(%q) error = %v, want one containing %q", tt.target, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check(%q): %v", tt.target, err)
+			}
+			if got.Rel != tt.rel {
+				t.Errorf("Rel = %q, want %q", got.Rel, tt.rel)
+			}
+			if filepath.ToSlash(got.NewDir) != tt.newDir {
+				t.Errorf("NewDir = %q, want %q", got.NewDir, tt.newDir)
+			}
+			if !filepath.IsAbs(got.Path) {
+				t.Errorf("Path %q is not absolute", got.Path)
+			}
+		})
+	}
+}
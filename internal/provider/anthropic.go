@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+
+	"dwight/internal/anthropic"
+)
+
+func init() {
+	Register("anthropic", func(ep Endpoint) Provider { return anthropicProvider{ep: ep} })
+}
+
+type anthropicProvider struct {
+	ep Endpoint
+}
+
+func (anthropicProvider) Name() string { return "anthropic" }
+
+func (anthropicProvider) Capabilities() Capabilities {
+	return Capabilities{ListModels: true}
+}
+
+func (p anthropicProvider) CheckModel(ctx context.Context, model string) (bool, error) {
+	if err := anthropic.CheckModel(p.ep, model); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p anthropicProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	models, err := anthropic.ListModels(ctx, p.ep)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModelInfo, len(models))
+	for i, m := range models {
+		out[i] = ModelInfo{Name: m.ID, ModifiedAt: m.CreatedAt}
+	}
+	return out, nil
+}
+
+func (anthropicProvider) PullModel(ctx context.Context, model string) error {
+	return ErrUnsupported
+}
+
+// ContextWindow is fixed: every current Claude model accepts 200k input tokens.
+func (anthropicProvider) ContextWindow(ctx context.Context, model string, opts Options) int {
+	return 200000
+}
+
+func (p anthropicProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
+	msgs := make([]anthropic.ChatMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		msgs[i] = anthropic.ChatMessage{Role: m.Role, Content: m.Content}
+	}
+
+	src, err := anthropic.ChatStream(ctx, anthropic.ChatRequest{
+		Endpoint:      p.ep,
+		Model:         req.Model,
+		Messages:      msgs,
+		System:        req.System,
+		Temperature:   req.Temperature,
+		MaxTokens:     req.Options.NumPredict,
+		TopP:          req.Options.TopP,
+		TopK:          req.Options.TopK,
+		StopSequences: req.Options.Stop,
+		Timeout:       req.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dst := make(chan StreamChunk)
+	go func() {
+		defer close(dst)
+		for chunk := range src {
+			dst <- StreamChunk{
+				Content:      chunk.Content,
+				Done:         chunk.Done,
+				Duration:     chunk.Duration,
+				PromptTokens: chunk.PromptTokens,
+				TotalTokens:  chunk.TotalTokens,
+				Err:          chunk.Err,
+			}
+		}
+	}()
+	return dst, nil
+}
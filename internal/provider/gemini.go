@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+
+	"dwight/internal/gemini"
+)
+
+func init() {
+	Register("gemini", func(ep Endpoint) Provider { return geminiProvider{ep: ep} })
+}
+
+type geminiProvider struct {
+	ep Endpoint
+}
+
+func (geminiProvider) Name() string { return "gemini" }
+
+func (geminiProvider) Capabilities() Capabilities { return Capabilities{Tools: true} }
+
+func (p geminiProvider) CheckModel(ctx context.Context, model string) (bool, error) {
+	if err := gemini.CheckModel(p.ep, model); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (geminiProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return nil, ErrUnsupported
+}
+
+func (geminiProvider) PullModel(ctx context.Context, model string) error {
+	return ErrUnsupported
+}
+
+func (p geminiProvider) ContextWindow(ctx context.Context, model string, opts Options) int {
+	n, err := gemini.InputTokenLimit(ctx, p.ep, model)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func (p geminiProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
+	msgs := make([]gemini.ChatMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		msgs[i] = gemini.ChatMessage{Role: m.Role, Content: m.Content, ToolName: m.ToolName}
+		for _, call := range m.ToolCalls {
+			msgs[i].FunctionCalls = append(msgs[i].FunctionCalls, gemini.FunctionCall{
+				Name: call.Name, Args: call.Arguments, Signature: call.Signature,
+			})
+		}
+	}
+	var tools []gemini.FunctionDeclaration
+	for _, t := range req.Tools {
+		tools = append(tools, gemini.FunctionDeclaration{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+	}
+
+	src, err := gemini.ChatStream(ctx, gemini.ChatRequest{
+		Endpoint:        p.ep,
+		Model:           req.Model,
+		Messages:        msgs,
+		System:          req.System,
+		Tools:           tools,
+		Temperature:     req.Temperature,
+		TopP:            req.Options.TopP,
+		TopK:            req.Options.TopK,
+		MaxOutputTokens: req.Options.NumPredict,
+		StopSequences:   req.Options.Stop,
+		Seed:            req.Options.Seed,
+		Timeout:         req.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dst := make(chan StreamChunk)
+	go func() {
+		defer close(dst)
+		for chunk := range src {
+			out := StreamChunk{
+				Content:      chunk.Content,
+				Done:         chunk.Done,
+				Duration:     chunk.Duration,
+				PromptTokens: chunk.PromptTokens,
+				TotalTokens:  chunk.TotalTokens,
+				Err:          chunk.Err,
+			}
+			for _, fc := range chunk.FunctionCalls {
+				out.ToolCalls = append(out.ToolCalls, ToolCall{Name: fc.Name, Arguments: fc.Args, Signature: fc.Signature})
+			}
+			dst <- out
+		}
+	}()
+	return dst, nil
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"context"
+
+	"dwight/internal/ollama"
+)
+
+func init() {
+	Register("ollama", func(ep Endpoint) Provider { return ollamaProvider{ep: ep} })
+}
+
+type ollamaProvider struct {
+	ep Endpoint
+}
+
+func (ollamaProvider) Name() string { return "ollama" }
+
+func (ollamaProvider) Capabilities() Capabilities {
+	return Capabilities{PullModels: true, ListModels: true, Tools: true}
+}
+
+func (p ollamaProvider) CheckModel(ctx context.Context, model string) (bool, error) {
+	return ollama.CheckModel(p.ep, model)
+}
+
+func (p ollamaProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	models, err := ollama.ListModels(p.ep)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModelInfo, len(models))
+	for i, m := range models {
+		out[i] = ModelInfo{Name: m.Name, ModifiedAt: m.ModifiedAt, Size: m.Size}
+	}
+	return out, nil
+}
+
+func (p ollamaProvider) PullModel(ctx context.Context, model string) error {
+	return ollama.PullModel(p.ep, model)
+}
+
+// ContextWindow prefers the profile's num_ctx, then what /api/show reports,
+// and only guesses from the model name when the server cannot answer.
+func (p ollamaProvider) ContextWindow(ctx context.Context, model string, opts Options) int {
+	if opts.NumCtx > 0 {
+		return opts.NumCtx
+	}
+	if n, err := ollama.ContextLength(ctx, p.ep, model); err == nil {
+		return n
+	}
+	return ollama.ContextWindowSize(model)
+}
+
+func (p ollamaProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
+	var msgs []ollama.ChatMessage
+	if req.System != "" {
+		msgs = append(msgs, ollama.ChatMessage{Role: "system", Content: req.System})
+	}
+	for _, m := range req.Messages {
+		msg := ollama.ChatMessage{Role: m.Role, Content: m.Content, ToolName: m.ToolName}
+		for _, call := range m.ToolCalls {
+			var tc ollama.ToolCall
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = call.Arguments
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		msgs = append(msgs, msg)
+	}
+	var tools []ollama.Tool
+	for _, t := range req.Tools {
+		tools = append(tools, ollama.Tool{
+			Type:     "function",
+			Function: ollama.ToolFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+
+	src, err := ollama.ChatStream(ctx, ollama.ChatRequest{
+		Endpoint:    p.ep,
+		Model:       req.Model,
+		Messages:    msgs,
+		Tools:       tools,
+		Temperature: req.Temperature,
+		Options: ollama.Options{
+			NumCtx:        req.Options.NumCtx,
+			TopP:          req.Options.TopP,
+			TopK:          req.Options.TopK,
+			RepeatPenalty: req.Options.RepeatPenalty,
+			Seed:          req.Options.Seed,
+			Stop:          req.Options.Stop,
+			NumPredict:    req.Options.NumPredict,
+		},
+		KeepAlive: req.Options.KeepAlive,
+		Timeout:   req.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dst := make(chan StreamChunk)
+	go func() {
+		defer close(dst)
+		for chunk := range src {
+			out := StreamChunk{
+				Content:      chunk.Content,
+				Done:         chunk.Done,
+				Duration:     chunk.Duration,
+				PromptTokens: chunk.PromptTokens,
+				TotalTokens:  chunk.TotalTokens,
+				Err:          chunk.Err,
+			}
+			for _, tc := range chunk.ToolCalls {
+				out.ToolCalls = append(out.ToolCalls, ToolCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+			}
+			dst <- out
+		}
+	}()
+	return dst, nil
+}
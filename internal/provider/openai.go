@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"dwight/internal/openai"
+)
+
+func init() {
+	Register("openai", func(ep Endpoint) Provider { return openaiProvider{ep: ep} })
+}
+
+// openaiProvider talks to any server speaking the OpenAI chat completions API
+// (llama.cpp server, vLLM, LM Studio, LocalAI).
+type openaiProvider struct {
+	ep Endpoint
+}
+
+func (openaiProvider) Name() string { return "openai" }
+
+func (openaiProvider) Capabilities() Capabilities {
+	return Capabilities{ListModels: true}
+}
+
+func (p openaiProvider) CheckModel(ctx context.Context, model string) (bool, error) {
+	return openai.CheckModel(ctx, p.ep, model)
+}
+
+func (p openaiProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	models, err := openai.ListModels(ctx, p.ep)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModelInfo, len(models))
+	for i, m := range models {
+		out[i] = ModelInfo{Name: m.ID}
+		if m.Created > 0 {
+			out[i].ModifiedAt = time.Unix(m.Created, 0).Format(time.RFC3339)
+		}
+	}
+	return out, nil
+}
+
+func (openaiProvider) PullModel(ctx context.Context, model string) error {
+	return ErrUnsupported
+}
+
+func (p openaiProvider) ContextWindow(ctx context.Context, model string, opts Options) int {
+	n, err := openai.ContextWindow(ctx, p.ep, model)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func (p openaiProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
+	var msgs []openai.ChatMessage
+	if req.System != "" {
+		msgs = append(msgs, openai.ChatMessage{Role: "system", Content: req.System})
+	}
+	for _, m := range req.Messages {
+		msgs = append(msgs, openai.ChatMessage{Role: m.Role, Content: m.Content})
+	}
+
+	src, err := openai.ChatStream(ctx, openai.ChatRequest{
+		Endpoint:      p.ep,
+		Model:         req.Model,
+		Messages:      msgs,
+		Temperature:   req.Temperature,
+		TopP:          req.Options.TopP,
+		TopK:          req.Options.TopK,
+		RepeatPenalty: req.Options.RepeatPenalty,
+		MaxTokens:     req.Options.NumPredict,
+		Stop:          req.Options.Stop,
+		Seed:          req.Options.Seed,
+		Timeout:       req.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dst := make(chan StreamChunk)
+	go func() {
+		defer close(dst)
+		for chunk := range src {
+			dst <- StreamChunk{
+				Content:      chunk.Content,
+				Done:         chunk.Done,
+				Duration:     chunk.Duration,
+				PromptTokens: chunk.PromptTokens,
+				TotalTokens:  chunk.TotalTokens,
+				Err:          chunk.Err,
+			}
+		}
+	}()
+	return dst, nil
+}
@@ -0,0 +1,197 @@
+// Package provider defines the backend-neutral chat interface the app talks to.
+// Each backend (Ollama, Gemini, ...) registers itself under the name stored in
+// ModelProfile.Provider, so callers never branch on the provider string.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dwight/internal/endpoint"
+	"dwight/internal/storage"
+)
+
+// ErrUnsupported is returned by optional operations a backend does not implement.
+var ErrUnsupported = errors.New("operation not supported by this provider")
+
+// Message is a single turn sent to a provider. Role "tool" carries the
+// result of the ToolName call made by the preceding assistant turn.
+type Message struct {
+	Role      string
+	Content   string
+	ToolCalls []ToolCall
+	ToolName  string
+}
+
+// ToolCall is a function call requested by the model.
+type ToolCall = storage.ToolCall
+
+// Tool declares a function the model may call. Parameters is a JSON Schema
+// object describing the arguments.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+}
+
+// ChatRequest configures a streaming chat call. System is sent the way each
+// backend expects it (system message, systemInstruction, ...).
+type ChatRequest struct {
+	Model       string
+	Messages    []Message
+	System      string
+	Temperature float64
+	Options     Options
+	Timeout     time.Duration
+	// Tools is only sent to backends whose Capabilities report Tools.
+	Tools []Tool
+}
+
+// Options are optional generation settings. Zero values mean "backend
+// default"; each backend maps the ones it supports.
+type Options struct {
+	NumCtx        int
+	TopP          float64
+	TopK          int
+	RepeatPenalty float64
+	Seed          int
+	Stop          []string
+	NumPredict    int    // max tokens to generate
+	KeepAlive     string // Ollama only
+}
+
+// OptionsFor copies a profile's generation settings.
+func OptionsFor(p storage.ModelProfile) Options {
+	return Options{
+		NumCtx:        p.NumCtx,
+		TopP:          p.TopP,
+		TopK:          p.TopK,
+		RepeatPenalty: p.RepeatPenalty,
+		Seed:          p.Seed,
+		Stop:          p.Stop,
+		NumPredict:    p.NumPredict,
+		KeepAlive:     p.KeepAlive,
+	}
+}
+
+// StreamChunk holds one chunk from a streaming response. Token counts are
+// reported on the final (Done) chunk at the latest.
+type StreamChunk struct {
+	Content      string
+	Done         bool
+	Duration     time.Duration
+	PromptTokens int
+	TotalTokens  int
+	ToolCalls    []ToolCall
+	Err          error
+}
+
+// ModelInfo describes a model reported by ListModels.
+type ModelInfo struct {
+	Name       string
+	ModifiedAt string
+	Size       int64
+}
+
+// Capabilities tells the UI which optional actions a backend supports.
+type Capabilities struct {
+	PullModels bool // models can be downloaded on demand
+	ListModels bool // ListModels enumerates available models
+	Tools      bool // ChatStream accepts Tools and reports ToolCalls
+}
+
+// Provider is implemented by every chat backend.
+type Provider interface {
+	// Name is the registry key, e.g. "ollama".
+	Name() string
+	Capabilities() Capabilities
+	// CheckModel reports whether the model is usable. A non-nil error means the
+	// backend is unreachable or misconfigured.
+	CheckModel(ctx context.Context, model string) (bool, error)
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+	PullModel(ctx context.Context, model string) error
+	// ContextWindow returns the context size in tokens, or 0 when unknown.
+	// It may hit the network; backends cache the answer per model.
+	ContextWindow(ctx context.Context, model string, opts Options) int
+	ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error)
+}
+
+// Endpoint carries per-profile connection settings. Empty fields fall back to
+// each backend's defaults and environment variables.
+type Endpoint = endpoint.Endpoint
+
+// Factory builds a provider bound to an endpoint.
+type Factory func(Endpoint) Provider
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Factory{}
+)
+
+// Register adds a backend under name. Later registrations replace earlier ones.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[name] = f
+}
+
+// New builds the provider registered under the (normalized) name.
+func New(name string, ep Endpoint) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported provider: %s", name)
+	}
+	return f(ep), nil
+}
+
+// ForProfile builds the provider a model profile points at.
+func ForProfile(p storage.ModelProfile) (Provider, error) {
+	return New(storage.NormalizeProvider(p.Provider), EndpointFor(p))
+}
+
+// EndpointFor resolves a profile's connection settings. The API key is read
+// from the env var the profile names, and header values may reference env
+// vars ($VAR) so secrets stay out of the profile file.
+func EndpointFor(p storage.ModelProfile) Endpoint {
+	ep := Endpoint{BaseURL: strings.TrimSpace(p.BaseURL)}
+	if env := strings.TrimSpace(p.APIKeyEnv); env != "" {
+		ep.APIKey = strings.TrimSpace(os.Getenv(env))
+	}
+	if len(p.Headers) > 0 {
+		ep.Headers = make(map[string]string, len(p.Headers))
+		for k, v := range p.Headers {
+			ep.Headers[k] = os.ExpandEnv(v)
+		}
+	}
+	return ep
+}
+
+// Names returns all registered provider names, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasModel checks if a model name matches any entry in models.
+func HasModel(name string, models []ModelInfo) bool {
+	for _, m := range models {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,399 @@
+// Package server exposes Dwight's model profiles over an OpenAI-compatible
+// HTTP API (/v1/models and /v1/chat/completions), so editors and scripts that
+// only speak that protocol can reuse curated profiles.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"dwight/internal/provider"
+	"dwight/internal/storage"
+)
+
+// Options configures the handler.
+type Options struct {
+	// LogConversations saves every completion to the conversation library.
+	LogConversations bool
+	// WorkContext is recorded on logged conversations.
+	WorkContext storage.WorkContext
+	// Logf reports each request; nil disables request logging.
+	Logf func(format string, args ...any)
+	// Token, when set, must be sent as "Authorization: Bearer <token>".
+	Token string
+}
+
+type server struct {
+	opts Options
+}
+
+// Handler returns the HTTP handler for the API. Profiles and settings are
+// re-read per request, so edits made in the TUI apply without a restart.
+func Handler(opts Options) http.Handler {
+	s := &server{opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/models", s.handleModels)
+	mux.HandleFunc("/v1/chat/completions", s.handleChat)
+	return s.guard(mux)
+}
+
+// guard rejects requests from web pages and, when a token is configured,
+// requests without it. Browsers send Origin on cross-site requests; editors
+// and scripts calling the API do not, or send the server's own origin.
+func (s *server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeError(w, http.StatusForbidden, "invalid_request_error", "cross-origin requests are not allowed")
+				return
+			}
+		}
+		if s.opts.Token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.opts.Token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "invalid_request_error", "missing or invalid bearer token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// --- Wire Types ---
+
+type modelObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type chatRequest struct {
+	Model         string        `json:"model"`
+	Messages      []chatMessage `json:"messages"`
+	Stream        bool          `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text flattens string or content-part array message content.
+func (m chatMessage) text() string {
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err == nil {
+		var b strings.Builder
+		for _, p := range parts {
+			if p.Type == "text" {
+				b.WriteString(p.Text)
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type choice struct {
+	Index        int            `json:"index"`
+	Message      *responseDelta `json:"message,omitempty"`
+	Delta        *responseDelta `json:"delta,omitempty"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+type responseDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type completion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []choice `json:"choices"`
+	Usage   *usage   `json:"usage,omitempty"`
+}
+
+// --- Handlers ---
+
+func (s *server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "use GET")
+		return
+	}
+	mc := storage.LoadModelConfig()
+	data := make([]modelObject, len(mc.Profiles))
+	for i, p := range mc.Profiles {
+		data[i] = modelObject{ID: p.Name, Object: "model", OwnedBy: "dwight:" + storage.NormalizeProvider(p.Provider)}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+func (s *server) handleChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "use POST")
+		return
+	}
+	// Forms and text/plain posts need no CORS preflight, so only JSON is accepted.
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "invalid_request_error", "Content-Type must be application/json")
+		return
+	}
+	var req chatRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 32<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("invalid JSON body: %v", err))
+		return
+	}
+	mc := storage.LoadModelConfig()
+	idx := profileIndex(mc, req.Model)
+	if idx < 0 {
+		writeError(w, http.StatusNotFound, "model_not_found", fmt.Sprintf("no profile named %q", req.Model))
+		return
+	}
+	profile := mc.Profiles[idx]
+	settings := storage.LoadSettings()
+
+	// The profile's system prompt comes first; request system messages extend it.
+	system := []string{}
+	if settings.MainPrompt != "" {
+		system = append(system, settings.MainPrompt)
+	}
+	if profile.SystemPrompt != "" {
+		system = append(system, profile.SystemPrompt)
+	}
+	var msgs []provider.Message
+	for _, m := range req.Messages {
+		switch m.Role {
+		case "system", "developer":
+			system = append(system, m.text())
+		case "user", "assistant":
+			msgs = append(msgs, provider.Message{Role: m.Role, Content: m.text()})
+		}
+	}
+	if len(msgs) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "messages must include a user message")
+		return
+	}
+
+	chatReq := provider.ChatRequest{
+		Model:       profile.Model,
+		Messages:    msgs,
+		System:      strings.TrimSpace(strings.Join(system, "\n\n")),
+		Temperature: profile.Temperature,
+		Options:     provider.OptionsFor(profile),
+		Timeout:     time.Duration(settings.ChatTimeout) * time.Second,
+	}
+	backend, err := provider.ForProfile(profile)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	started := time.Now()
+	ch, err := backend.ChatStream(r.Context(), chatReq)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "upstream_error", err.Error())
+		return
+	}
+
+	id := fmt.Sprintf("chatcmpl-%d", started.UnixNano())
+	var content string
+	var last provider.StreamChunk
+	if req.Stream {
+		content, last, err = s.stream(w, ch, id, profile.Name, req.StreamOptions != nil && req.StreamOptions.IncludeUsage)
+	} else {
+		content, last, err = collect(r.Context(), ch)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "upstream_error", err.Error())
+		} else {
+			stop := "stop"
+			writeJSON(w, http.StatusOK, completion{
+				ID: id, Object: "chat.completion", Created: started.Unix(), Model: profile.Name,
+				Choices: []choice{{Message: &responseDelta{Role: "assistant", Content: content}, FinishReason: &stop}},
+				Usage:   usageFrom(last),
+			})
+		}
+	}
+	// A client that hangs up mid-stream cancels the upstream request, which
+	// ends the stream early but without an error; don't log that as complete.
+	if err == nil && r.Context().Err() != nil {
+		err = fmt.Errorf("client disconnected: %w", r.Context().Err())
+	}
+
+	if s.opts.Logf != nil {
+		status := "ok"
+		if err != nil {
+			status = err.Error()
+		}
+		s.opts.Logf("%s %s -> %s (%s, %d tok) %s", r.Method, r.URL.Path, profile.Name, time.Since(started).Round(time.Millisecond), last.TotalTokens, status)
+	}
+	if err == nil && s.opts.LogConversations {
+		if err := s.logConversation(profile, req.Messages, content, last, started); err != nil && s.opts.Logf != nil {
+			s.opts.Logf("failed to save conversation: %v", err)
+		}
+	}
+}
+
+// stream relays chunks as OpenAI chat.completion.chunk SSE events.
+func (s *server) stream(w http.ResponseWriter, ch <-chan provider.StreamChunk, id, model string, includeUsage bool) (string, provider.StreamChunk, error) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	created := time.Now().Unix()
+	send := func(v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	chunk := func(delta responseDelta, finish *string) completion {
+		return completion{ID: id, Object: "chat.completion.chunk", Created: created, Model: model,
+			Choices: []choice{{Delta: &delta, FinishReason: finish}}}
+	}
+
+	send(chunk(responseDelta{Role: "assistant"}, nil))
+	var content strings.Builder
+	var last provider.StreamChunk
+	for c := range ch {
+		if c.Err != nil {
+			send(map[string]any{"error": map[string]string{"message": c.Err.Error(), "type": "upstream_error"}})
+			return content.String(), last, c.Err
+		}
+		if c.Content != "" {
+			content.WriteString(c.Content)
+			send(chunk(responseDelta{Content: c.Content}, nil))
+		}
+		if c.Done {
+			last = c
+			break
+		}
+	}
+	stop := "stop"
+	send(chunk(responseDelta{}, &stop))
+	if includeUsage {
+		send(completion{ID: id, Object: "chat.completion.chunk", Created: created, Model: model,
+			Choices: []choice{}, Usage: usageFrom(last)})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return content.String(), last, nil
+}
+
+func collect(ctx context.Context, ch <-chan provider.StreamChunk) (string, provider.StreamChunk, error) {
+	var content strings.Builder
+	var last provider.StreamChunk
+	for c := range ch {
+		if c.Err != nil {
+			return "", last, c.Err
+		}
+		content.WriteString(c.Content)
+		if c.Done {
+			last = c
+			break
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return "", last, err
+	}
+	return content.String(), last, nil
+}
+
+// logConversation stores the request transcript plus the reply.
+func (s *server) logConversation(profile storage.ModelProfile, in []chatMessage, reply string, last provider.StreamChunk, started time.Time) error {
+	var msgs []storage.ConvMessage
+	parent := ""
+	add := func(m storage.ConvMessage) {
+		m.ID = storage.NewMessageID()
+		m.ParentID = parent
+		parent = m.ID
+		msgs = append(msgs, m)
+	}
+	for _, m := range in {
+		if m.Role == "user" || m.Role == "assistant" {
+			add(storage.ConvMessage{Role: m.Role, Content: m.text(), Timestamp: started})
+		}
+	}
+	add(storage.ConvMessage{
+		Role: "assistant", Content: reply, Timestamp: time.Now(),
+		Duration: last.Duration, PromptTokens: last.PromptTokens, TotalTokens: last.TotalTokens,
+	})
+	title := storage.GenerateTitle(msgs)
+	wc := s.opts.WorkContext
+	return storage.SaveConversation(&storage.Conversation{
+		ID:          storage.NewConversationSlug(title),
+		Title:       title,
+		Model:       profile.Model,
+		ProfileName: profile.Name,
+		Created:     started,
+		Messages:    msgs,
+		Tags:        []string{"serve"},
+		WorkingDir:  wc.WorkingDir,
+		GitRoot:     wc.GitRoot,
+		OriginHint:  wc.OriginHint,
+	})
+}
+
+// profileIndex matches a model id against profile names, ignoring case and
+// treating spaces, dashes and underscores alike ("coder-assistant").
+func profileIndex(mc storage.ModelConfig, id string) int {
+	if i := mc.Index(id); i >= 0 {
+		return i
+	}
+	norm := func(s string) string {
+		return strings.NewReplacer(" ", "-", "_", "-").Replace(strings.ToLower(strings.TrimSpace(s)))
+	}
+	for i, p := range mc.Profiles {
+		if norm(p.Name) == norm(id) {
+			return i
+		}
+	}
+	return -1
+}
+
+func usageFrom(c provider.StreamChunk) *usage {
+	completionTokens := c.TotalTokens - c.PromptTokens
+	if completionTokens < 0 {
+		completionTokens = 0
+	}
+	return &usage{PromptTokens: c.PromptTokens, CompletionTokens: completionTokens, TotalTokens: c.TotalTokens}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, kind, msg string) {
+	writeJSON(w, status, map[string]any{"error": map[string]string{"message": msg, "type": kind}})
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ConversationFilter narrows ListConversations results. Zero fields match
+// everything.
+type ConversationFilter struct {
+	Project string    // repo/folder name, or a substring of GitRoot, OriginHint or WorkingDir
+	Model   string    // substring of the model or profile name
+	Tag     string    // exact tag
+	Since   time.Time // last modified at or after
+	Until   time.Time // last modified before
+}
+
+func (f ConversationFilter) Match(c ConversationMeta) bool {
+	if f.Project != "" && !matchesProject(c, f.Project) {
+		return false
+	}
+	if f.Model != "" {
+		q := strings.ToLower(f.Model)
+		if !strings.Contains(strings.ToLower(c.Model), q) && !strings.Contains(strings.ToLower(c.ProfileName), q) {
+			return false
+		}
+	}
+	if f.Tag != "" {
+		found := false
+		for _, t := range c.Tags {
+			if strings.EqualFold(t, f.Tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && c.LastModified.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !c.LastModified.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+func matchesProject(c ConversationMeta, project string) bool {
+	label := ContextLabel(WorkContext{WorkingDir: c.WorkingDir, GitRoot: c.GitRoot, OriginHint: c.OriginHint})
+	if strings.EqualFold(label, project) {
+		return true
+	}
+	q := strings.ToLower(project)
+	for _, field := range []string{c.GitRoot, c.OriginHint, c.WorkingDir} {
+		if field != "" && strings.Contains(strings.ToLower(field), q) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameProject reports whether a conversation was started in the repo (or, outside
+// git, the directory) described by wc.
+func SameProject(c ConversationMeta, wc WorkContext) bool {
+	if wc.GitRoot != "" {
+		return c.GitRoot != "" && filepath.Clean(c.GitRoot) == filepath.Clean(wc.GitRoot)
+	}
+	return wc.WorkingDir != "" && c.GitRoot == "" && filepath.Clean(c.WorkingDir) == filepath.Clean(wc.WorkingDir)
+}
@@ -6,8 +6,10 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // Paths returns the base data directory (~/.local/share/dwight/).
@@ -21,6 +23,75 @@ func DataDir() string {
 type Config struct {
 	TemplatesDir string   `json:"templates_dir"`
 	FileTypes    []string `json:"file_types"`
+	// Projects holds per-project settings keyed by git root (or directory).
+	// They live here rather than in the repo so a cloned project cannot
+	// pre-approve its own commands.
+	Projects map[string]ProjectConfig `json:"projects,omitempty"`
+	// DenyWrites lists glob patterns, relative to the project root, that
+	// accepted code and tools may never write. Null means
+	// DefaultDenyWrites; an empty list allows everything inside the project.
+	DenyWrites []string `json:"deny_writes"`
+	// Hooks run after accepted code is written, in every project; per-project
+	// hooks run after them.
+	Hooks []Hook `json:"hooks,omitempty"`
+}
+
+// Hook is a command run after accepted code is written, e.g. "gofmt -w {file}"
+// or "go build ./...".
+type Hook struct {
+	// Match limits the hook to written files matching this glob ("*.go",
+	// "web/**/*.ts"); empty matches every file.
+	Match string `json:"match,omitempty"`
+	// Command runs with sh -c in the project root. "{file}" is replaced by a
+	// matching file, relative to the root, and the hook runs once per file;
+	// without it the hook runs once per apply.
+	Command string `json:"command"`
+	Timeout int    `json:"timeout,omitempty"` // seconds; default 120, max 600
+}
+
+// DefaultDenyWrites protects git internals, dotfiles such as .env, anything
+// in dot directories such as .github or .vscode, and keys. Patterns without a
+// slash match the file name; "**" spans directories.
+var DefaultDenyWrites = []string{
+	".git/**",
+	".*", "**/.*/**",
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.keystore", "*.jks",
+	"id_rsa*", "id_dsa*", "id_ecdsa*", "id_ed25519*",
+}
+
+// DenyList returns the write deny-list in effect.
+func (c Config) DenyList() []string {
+	if c.DenyWrites == nil {
+		return DefaultDenyWrites
+	}
+	return c.DenyWrites
+}
+
+// ProjectConfig is configuration that applies inside one project.
+type ProjectConfig struct {
+	// AllowedCommands run without asking when the model calls run_command.
+	// An entry ending in "*" matches any command with that prefix.
+	AllowedCommands []string `json:"allowed_commands,omitempty"`
+	// Hooks run after accepted code is written in this project.
+	Hooks []Hook `json:"hooks,omitempty"`
+}
+
+// Project returns the settings for the project rooted at root.
+func (c Config) Project(root string) ProjectConfig {
+	return c.Projects[root]
+}
+
+// HooksFor returns the global hooks followed by those of the project at root.
+func (c Config) HooksFor(root string) []Hook {
+	return append(append([]Hook{}, c.Hooks...), c.Projects[root].Hooks...)
+}
+
+// SetProject replaces the settings for the project rooted at root.
+func (c *Config) SetProject(root string, p ProjectConfig) {
+	if c.Projects == nil {
+		c.Projects = map[string]ProjectConfig{}
+	}
+	c.Projects[root] = p
 }
 
 type configFile struct {
@@ -70,9 +141,12 @@ func defaultConfig() Config {
 // --- App Settings ---
 
 type Settings struct {
-	MainPrompt  string `json:"main_prompt"`
-	UserName    string `json:"user_name"`
-	ChatTimeout int    `json:"chat_timeout"` // seconds
+	MainPrompt      string `json:"main_prompt"`
+	UserName        string `json:"user_name"`
+	ChatTimeout     int    `json:"chat_timeout"`               // seconds
+	ContextStrategy string `json:"context_strategy,omitempty"` // summarize (default), truncate or off
+	Tools           bool   `json:"tools,omitempty"`            // offer local tools to models with function calling
+	EditBlocks      bool   `json:"edit_blocks,omitempty"`      // teach models the SEARCH/REPLACE edit format
 }
 
 func LoadSettings() Settings {
@@ -108,6 +182,140 @@ type ModelProfile struct {
 	Model        string  `json:"model"`
 	SystemPrompt string  `json:"system_prompt"`
 	Temperature  float64 `json:"temperature"`
+	// Endpoint overrides; empty values fall back to the provider's env vars.
+	BaseURL   string            `json:"base_url,omitempty"`    // API root, e.g. http://gpu-box:11434
+	APIKeyEnv string            `json:"api_key_env,omitempty"` // env var holding the API key
+	Headers   map[string]string `json:"headers,omitempty"`     // extra HTTP headers; values may use $VAR
+
+	// Generation options; zero values leave the model's defaults in place.
+	NumCtx        int      `json:"num_ctx,omitempty"`
+	TopP          float64  `json:"top_p,omitempty"`
+	TopK          int      `json:"top_k,omitempty"`
+	RepeatPenalty float64  `json:"repeat_penalty,omitempty"`
+	Seed          int      `json:"seed,omitempty"`
+	Stop          []string `json:"stop,omitempty"`
+	NumPredict    int      `json:"num_predict,omitempty"`
+	KeepAlive     string   `json:"keep_alive,omitempty"` // Ollama only, e.g. "10m"
+}
+
+// OptionsString renders the generation options as "key=value" pairs, the
+// format SetOptions reads back. Stop sequences are joined with "|" and
+// quoted when they contain spaces, quotes or control characters.
+func (p ModelProfile) OptionsString() string {
+	var parts []string
+	if p.NumCtx > 0 {
+		parts = append(parts, fmt.Sprintf("num_ctx=%d", p.NumCtx))
+	}
+	if p.TopP > 0 {
+		parts = append(parts, fmt.Sprintf("top_p=%g", p.TopP))
+	}
+	if p.TopK > 0 {
+		parts = append(parts, fmt.Sprintf("top_k=%d", p.TopK))
+	}
+	if p.RepeatPenalty > 0 {
+		parts = append(parts, fmt.Sprintf("repeat_penalty=%g", p.RepeatPenalty))
+	}
+	if p.Seed != 0 {
+		parts = append(parts, fmt.Sprintf("seed=%d", p.Seed))
+	}
+	if len(p.Stop) > 0 {
+		stop := strings.Join(p.Stop, "|")
+		if strings.ContainsFunc(stop, func(r rune) bool { return unicode.IsSpace(r) || r == '"' || !unicode.IsPrint(r) }) {
+			stop = strconv.Quote(stop)
+		}
+		parts = append(parts, "stop="+stop)
+	}
+	if p.NumPredict != 0 {
+		parts = append(parts, fmt.Sprintf("num_predict=%d", p.NumPredict))
+	}
+	if p.KeepAlive != "" {
+		parts = append(parts, "keep_alive="+p.KeepAlive)
+	}
+	return strings.Join(parts, " ")
+}
+
+// SetOptions parses space-separated "key=value" pairs (see OptionsString) and
+// replaces the profile's generation options. A value may be double-quoted,
+// with Go escapes, to hold spaces: stop="User: |\n\n".
+func (p *ModelProfile) SetOptions(s string) error {
+	fields, err := splitOptions(s)
+	if err != nil {
+		return err
+	}
+	var next ModelProfile
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return fmt.Errorf("expected key=value, got %q", field)
+		}
+		if strings.HasPrefix(value, `"`) {
+			if value, err = strconv.Unquote(value); err != nil || value == "" {
+				return fmt.Errorf("invalid quoted value in %q", field)
+			}
+		}
+		switch strings.ToLower(key) {
+		case "num_ctx":
+			next.NumCtx, err = strconv.Atoi(value)
+		case "top_p":
+			next.TopP, err = strconv.ParseFloat(value, 64)
+		case "top_k":
+			next.TopK, err = strconv.Atoi(value)
+		case "repeat_penalty":
+			next.RepeatPenalty, err = strconv.ParseFloat(value, 64)
+		case "seed":
+			next.Seed, err = strconv.Atoi(value)
+		case "stop":
+			for _, stop := range strings.Split(value, "|") {
+				if stop != "" { // "a||b" is a typo, not an empty stop sequence
+					next.Stop = append(next.Stop, stop)
+				}
+			}
+		case "num_predict":
+			next.NumPredict, err = strconv.Atoi(value)
+		case "keep_alive":
+			next.KeepAlive = value
+		default:
+			return fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s: %q", key, value)
+		}
+	}
+	p.NumCtx, p.TopP, p.TopK, p.RepeatPenalty = next.NumCtx, next.TopP, next.TopK, next.RepeatPenalty
+	p.Seed, p.Stop, p.NumPredict, p.KeepAlive = next.Seed, next.Stop, next.NumPredict, next.KeepAlive
+	return nil
+}
+
+// splitOptions splits s on whitespace outside double quotes, keeping the
+// quotes so SetOptions can unquote the value.
+func splitOptions(s string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	quoted, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(r):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+			continue
+		}
+		field.WriteRune(r)
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in options")
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
 }
 
 type ModelConfig struct {
@@ -115,6 +323,17 @@ type ModelConfig struct {
 	CurrentProfile int            `json:"current_profile"`
 }
 
+// Index returns the position of the profile named name (case-insensitive),
+// or -1.
+func (mc ModelConfig) Index(name string) int {
+	for i, p := range mc.Profiles {
+		if strings.EqualFold(p.Name, strings.TrimSpace(name)) {
+			return i
+		}
+	}
+	return -1
+}
+
 func defaultModel() string {
 	if m := os.Getenv("DWIGHT_MODEL"); m != "" {
 		return m
@@ -163,12 +382,47 @@ func (mc *ModelConfig) Current() ModelProfile {
 	return DefaultProfiles[0]
 }
 
+// ParseHeaders reads "Name: value; Other: value" into a header map.
+func ParseHeaders(s string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+// FormatHeaders is the inverse of ParseHeaders, sorted by name.
+func FormatHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + ": " + headers[name]
+	}
+	return strings.Join(pairs, "; ")
+}
+
 func NormalizeProvider(provider string) string {
 	switch strings.ToLower(strings.TrimSpace(provider)) {
 	case "", "ollama":
 		return "ollama"
 	case "gemini", "google":
 		return "gemini"
+	case "anthropic", "claude":
+		return "anthropic"
+	case "openai", "openai-compatible", "llamacpp", "llama.cpp", "vllm", "lmstudio", "localai":
+		return "openai"
 	default:
 		return strings.ToLower(strings.TrimSpace(provider))
 	}
@@ -192,15 +446,38 @@ type Conversation struct {
 	WorkingDir string `json:"working_dir,omitempty"`
 	GitRoot    string `json:"git_root,omitempty"`
 	OriginHint string `json:"origin_hint,omitempty"`
+	// Summary condenses Messages[:SummarizedThrough] once they no longer fit the
+	// model's context window; it is sent as a pinned system message instead.
+	Summary           string `json:"summary,omitempty"`
+	SummarizedThrough int    `json:"summarized_through,omitempty"`
+	// Branches holds messages on inactive branches of the tree (see tree.go).
+	Branches []ConvMessage `json:"branches,omitempty"`
 }
 
+// ConvMessage is one node of the conversation tree. Edited prompts and
+// regenerated replies are siblings that share a ParentID.
 type ConvMessage struct {
+	ID           string        `json:"id,omitempty"`
+	ParentID     string        `json:"parent_id,omitempty"`
 	Role         string        `json:"role"`
 	Content      string        `json:"content"`
 	Timestamp    time.Time     `json:"timestamp"`
 	Duration     time.Duration `json:"duration"`
 	PromptTokens int           `json:"prompt_tokens"`
 	TotalTokens  int           `json:"total_tokens"`
+	// ToolCalls are the functions an assistant turn asked to run; a "tool"
+	// message carrying ToolName holds one call's result.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+// ToolCall is a function call requested by the model.
+type ToolCall struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	// Signature is opaque provider state that must be echoed back with the
+	// call (Gemini thought signatures).
+	Signature string `json:"signature,omitempty"`
 }
 
 type ConversationMeta struct {
@@ -215,6 +492,7 @@ type ConversationMeta struct {
 	WorkingDir   string    `json:"working_dir,omitempty"`
 	GitRoot      string    `json:"git_root,omitempty"`
 	OriginHint   string    `json:"origin_hint,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
 }
 
 // Where returns a short label for lists (origin, repo name, or working directory).
@@ -292,8 +570,20 @@ func SaveConversation(conv *Conversation) error {
 	return nil
 }
 
+// conversationPath maps id to its file, refusing ids that would leave the
+// conversations directory.
+func conversationPath(id string) (string, error) {
+	if !filepath.IsLocal(id) || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid conversation id %q", id)
+	}
+	return filepath.Join(ConversationsDir(), id+".json"), nil
+}
+
 func LoadConversation(id string) (*Conversation, error) {
-	path := filepath.Join(ConversationsDir(), id+".json")
+	path, err := conversationPath(id)
+	if err != nil {
+		return nil, err
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -302,11 +592,16 @@ func LoadConversation(id string) (*Conversation, error) {
 	if err := json.Unmarshal(data, &conv); err != nil {
 		return nil, err
 	}
+	conv.EnsureMessageIDs()
 	return &conv, nil
 }
 
 func DeleteConversation(id string) error {
-	return os.Remove(filepath.Join(ConversationsDir(), id+".json"))
+	path, err := conversationPath(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
 }
 
 func ListConversations() ([]ConversationMeta, error) {
@@ -336,6 +631,7 @@ func ListConversations() ([]ConversationMeta, error) {
 			LastModified: conv.LastModified, MessageCount: conv.MessageCount,
 			TotalTokens: conv.TotalTokens,
 			WorkingDir:  conv.WorkingDir, GitRoot: conv.GitRoot, OriginHint: conv.OriginHint,
+			Tags: conv.Tags,
 		})
 	}
 
@@ -370,10 +666,16 @@ func ExportMarkdown(conv *Conversation) string {
 	}
 	md.WriteString(fmt.Sprintf("**Messages:** %d | **Tokens:** %d  \n\n---\n\n", conv.MessageCount, conv.TotalTokens))
 
+	all := conv.AllMessages()
 	for _, msg := range conv.Messages {
-		if msg.Role == "user" {
+		switch msg.Role {
+		case "user":
 			md.WriteString("## User\n\n")
-		} else {
+		case "tool":
+			md.WriteString(fmt.Sprintf("## Tool: %s\n\n", msg.ToolName))
+		case "hook":
+			md.WriteString("## Post-apply hook\n\n")
+		default:
 			md.WriteString("## Assistant\n\n")
 		}
 		var meta []string
@@ -386,10 +688,30 @@ func ExportMarkdown(conv *Conversation) string {
 		if msg.Role == "assistant" && msg.TotalTokens > 0 {
 			meta = append(meta, fmt.Sprintf("%d tokens", msg.TotalTokens))
 		}
+		if len(conv.Branches) > 0 {
+			if siblings := SiblingIDs(all, msg.ID); len(siblings) > 1 {
+				for i, id := range siblings {
+					if id == msg.ID {
+						meta = append(meta, fmt.Sprintf("version %d of %d", i+1, len(siblings)))
+					}
+				}
+			}
+		}
 		if len(meta) > 0 {
 			md.WriteString(fmt.Sprintf("*%s*\n\n", strings.Join(meta, " | ")))
 		}
-		md.WriteString(msg.Content + "\n\n---\n\n")
+		if msg.Role == "tool" || msg.Role == "hook" {
+			md.WriteString("```\n" + strings.TrimRight(msg.Content, "\n") + "\n```\n\n---\n\n")
+			continue
+		}
+		if msg.Content != "" {
+			md.WriteString(msg.Content + "\n\n")
+		}
+		for _, call := range msg.ToolCalls {
+			args, _ := json.Marshal(call.Arguments)
+			md.WriteString(fmt.Sprintf("*Called `%s` %s*\n\n", call.Name, args))
+		}
+		md.WriteString("---\n\n")
 	}
 	return md.String()
 }
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetOptions(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ModelProfile
+		err  bool
+	}{
+		{in: "", want: ModelProfile{}},
+		{in: "num_ctx=8192 top_p=0.9 top_k=40", want: ModelProfile{NumCtx: 8192, TopP: 0.9, TopK: 40}},
+		{in: "repeat_penalty=1.1 seed=-1 num_predict=256 keep_alive=10m", want: ModelProfile{RepeatPenalty: 1.1, Seed: -1, NumPredict: 256, KeepAlive: "10m"}},
+		{in: "NUM_CTX=2048", want: ModelProfile{NumCtx: 2048}},
+		{in: "stop=</s>|###", want: ModelProfile{Stop: []string{"</s>", "###"}}},
+		{in: "stop=a||b|", want: ModelProfile{Stop: []string{"a", "b"}}},
+		{in: `stop="User: |\n\n"`, want: ModelProfile{Stop: []string{"User: ", "\n\n"}}},
+		{in: `stop="a b" seed=1`, want: ModelProfile{Stop: []string{"a b"}, Seed: 1}},
+		{in: "num_ctx=lots", err: true},
+		{in: "num_ctx", err: true},
+		{in: "num_ctx=", err: true},
+		{in: "colour=blue", err: true},
+		{in: `stop="unterminated`, err: true},
+		{in: `stop=""`, err: true},
+	}
+	for _, tt := range tests {
+		p := ModelProfile{Name: "test", NumCtx: 1, Stop: []string{"old"}}
+		err := p.SetOptions(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("SetOptions(%q) = %+v, want an error", tt.in, p)
+			}
+			if p.NumCtx != 1 || len(p.Stop) != 1 {
+				t.Errorf("SetOptions(%q) changed the profile despite failing", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SetOptions(%q): %v", tt.in, err)
+			continue
+		}
+		tt.want.Name = "test"
+		if !reflect.DeepEqual(p, tt.want) {
+			t.Errorf("SetOptions(%q) = %+v, want %+v", tt.in, p, tt.want)
+		}
+		// OptionsString must read back to the same options.
+		var again ModelProfile
+		if err := again.SetOptions(p.OptionsString()); err != nil {
+			t.Errorf("SetOptions(OptionsString()) for %q: %v", tt.in, err)
+			continue
+		}
+		again.Name = "test"
+		if !reflect.DeepEqual(again, p) {
+			t.Errorf("round trip of %q = %+v, want %+v", tt.in, again, p)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+	}{
+		{"", nil},
+		{"  ;  ", nil},
+		{"X-Api-Key: $KEY", map[string]string{"X-Api-Key": "$KEY"}},
+		{"A: 1; B : two words ;", map[string]string{"A": "1", "B": "two words"}},
+		{"Authorization: Bearer a:b", map[string]string{"Authorization": "Bearer a:b"}},
+		{"no colon; C: 3", map[string]string{"C": "3"}},
+		{": nameless", nil},
+		{"Empty:", map[string]string{"Empty": ""}},
+	}
+	for _, tt := range tests {
+		got := ParseHeaders(tt.in)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseHeaders(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+		if got != nil && !reflect.DeepEqual(ParseHeaders(FormatHeaders(got)), got) {
+			t.Errorf("FormatHeaders(%v) does not read back", got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Conversations are stored as a message tree: every ConvMessage carries an ID
+// and the ID of the message it answers or follows. Conversation.Messages is
+// the active branch (root to leaf) so older readers and exports see a plain
+// transcript; Conversation.Branches holds every node that is not on it.
+
+var (
+	messageIDMu   sync.Mutex
+	lastMessageID int64
+)
+
+// NewMessageID returns a unique, increasing message id.
+func NewMessageID() string {
+	messageIDMu.Lock()
+	defer messageIDMu.Unlock()
+	id := time.Now().UnixNano()
+	if id <= lastMessageID {
+		id = lastMessageID + 1
+	}
+	lastMessageID = id
+	return strconv.FormatInt(id, 10)
+}
+
+// messageIDLess orders ids by creation. Ids are decimal, so a shorter id
+// (legacy backfill) always sorts before a longer one.
+func messageIDLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// EnsureMessageIDs upgrades flat conversations saved before messages had ids by
+// chaining Messages into a single branch.
+func (c *Conversation) EnsureMessageIDs() {
+	next := 1
+	parent := ""
+	for i := range c.Messages {
+		if c.Messages[i].ID == "" {
+			c.Messages[i].ID = strconv.Itoa(next)
+			c.Messages[i].ParentID = parent
+			next++
+		}
+		parent = c.Messages[i].ID
+	}
+}
+
+// AllMessages returns every node of the tree, active branch last.
+func (c *Conversation) AllMessages() []ConvMessage {
+	all := make([]ConvMessage, 0, len(c.Branches)+len(c.Messages))
+	all = append(all, c.Branches...)
+	return append(all, c.Messages...)
+}
+
+// SiblingIDs lists the ids of messages sharing id's parent, oldest first.
+func SiblingIDs(nodes []ConvMessage, id string) []string {
+	parent, ok := "", false
+	for _, n := range nodes {
+		if n.ID == id {
+			parent, ok = n.ParentID, true
+			break
+		}
+	}
+	if !ok {
+		return nil
+	}
+	var ids []string
+	for _, n := range nodes {
+		if n.ParentID == parent {
+			ids = append(ids, n.ID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return messageIDLess(ids[i], ids[j]) })
+	return ids
+}
+
+// SwitchBranch makes the branch through id active, following the newest child
+// at each later fork. It reports false if id is not in the tree.
+func (c *Conversation) SwitchBranch(id string) bool {
+	path, rest, ok := BranchPath(c.AllMessages(), id)
+	if ok {
+		c.Messages, c.Branches = path, rest
+	}
+	return ok
+}
+
+// BranchPath splits nodes into the path from the root through id down to its
+// newest leaf, and everything else.
+func BranchPath(nodes []ConvMessage, id string) (path, rest []ConvMessage, ok bool) {
+	byID := make(map[string]int, len(nodes))
+	newestChild := make(map[string]string)
+	for i, n := range nodes {
+		byID[n.ID] = i
+		if cur, ok := newestChild[n.ParentID]; !ok || messageIDLess(cur, n.ID) {
+			newestChild[n.ParentID] = n.ID
+		}
+	}
+	if _, found := byID[id]; !found {
+		return nil, nil, false
+	}
+
+	leaf := id
+	for {
+		child, ok := newestChild[leaf]
+		if !ok {
+			break
+		}
+		leaf = child
+	}
+
+	onPath := make(map[string]bool)
+	for cur := leaf; cur != ""; {
+		i, ok := byID[cur]
+		if !ok || onPath[cur] {
+			break
+		}
+		onPath[cur] = true
+		path = append(path, nodes[i])
+		cur = nodes[i].ParentID
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	for _, n := range nodes {
+		if !onPath[n.ID] {
+			rest = append(rest, n)
+		}
+	}
+	return path, rest, true
+}
@@ -75,6 +75,9 @@ var (
 
 	DiffRemove = lipgloss.NewStyle().
 			Foreground(Red)
+
+	DiffHunk = lipgloss.NewStyle().
+			Foreground(Blue)
 )
 
 // Footer builds a consistent footer from key-description pairs.
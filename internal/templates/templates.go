@@ -0,0 +1,220 @@
+// Package templates loads reusable prompts from config.TemplatesDir.
+//
+// A template is a .md or .txt file. Its body may contain {{variables}}, which
+// are prompted for on insertion, plus the built-ins {{selection}},
+// {{git_diff}}, {{date}} and {{cwd}}. An optional front-matter block pins a
+// profile and temperature:
+//
+//	---
+//	description: Review a diff
+//	profile: reviewer
+//	temperature: 0.2
+//	---
+//	Review this change:
+//	{{git_diff}}
+package templates
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Built-in variables filled in by Dwight rather than prompted for.
+const (
+	VarSelection = "selection"
+	VarGitDiff   = "git_diff"
+	VarDate      = "date"
+	VarCwd       = "cwd"
+)
+
+// maxGitDiff caps how much of `git diff` a template can pull in.
+const maxGitDiff = 64 * 1024
+
+var varPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+type Template struct {
+	Name        string // path relative to the templates dir, without extension
+	Path        string
+	Description string
+	Profile     string   // profile to switch to, if pinned
+	Temperature *float64 // temperature override, if pinned
+	Body        string
+}
+
+// Load reads every .md/.txt template under dir, sorted by name. A template
+// that fails to parse is left out and described in skipped instead.
+func Load(dir string) (out []Template, skipped []string, err error) {
+	if dir == "" {
+		return nil, nil, nil
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".md" && ext != ".txt" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		t, err := Parse(strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel)), string(data))
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", rel, err))
+			return nil
+		}
+		t.Path = path
+		out = append(out, t)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, skipped, nil
+}
+
+// Parse splits optional front-matter from the template body.
+func Parse(name, data string) (Template, error) {
+	t := Template{Name: name}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	if !strings.HasPrefix(data, "---\n") {
+		t.Body = strings.TrimSpace(data)
+		return t, nil
+	}
+	rest := data[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return t, fmt.Errorf("unterminated front-matter")
+	}
+	header := rest[:end]
+	body := rest[end+len("\n---"):]
+	if i := strings.IndexByte(body, '\n'); i >= 0 {
+		body = body[i+1:]
+	} else {
+		body = ""
+	}
+	t.Body = strings.TrimSpace(body)
+
+	for _, line := range strings.Split(header, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return t, fmt.Errorf("invalid front-matter line %q", line)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "description", "title":
+			t.Description = value
+		case "profile":
+			t.Profile = value
+		case "temperature", "temp":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return t, fmt.Errorf("invalid temperature %q", value)
+			}
+			t.Temperature = &f
+		}
+	}
+	return t, nil
+}
+
+// Variables returns the user-supplied variables in body, in order of first use.
+func Variables(body string) []string {
+	var vars []string
+	seen := map[string]bool{}
+	for _, match := range varPattern.FindAllStringSubmatch(body, -1) {
+		name := match[1]
+		if seen[name] || IsBuiltin(name) {
+			continue
+		}
+		seen[name] = true
+		vars = append(vars, name)
+	}
+	return vars
+}
+
+func IsBuiltin(name string) bool {
+	switch name {
+	case VarSelection, VarGitDiff, VarDate, VarCwd:
+		return true
+	}
+	return false
+}
+
+// Uses reports whether body references the variable name.
+func Uses(body, name string) bool {
+	for _, match := range varPattern.FindAllStringSubmatch(body, -1) {
+		if match[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Builtins resolves the built-in variables body actually uses. git_diff is
+// only computed when referenced.
+func Builtins(body, cwd, selection string) map[string]string {
+	vars := map[string]string{
+		VarSelection: selection,
+		VarDate:      time.Now().Format("2006-01-02"),
+		VarCwd:       cwd,
+	}
+	if Uses(body, VarGitDiff) {
+		vars[VarGitDiff] = gitDiff(cwd)
+	}
+	return vars
+}
+
+// Render substitutes vars into body. Unknown variables are left untouched.
+func Render(body string, vars map[string]string) string {
+	return varPattern.ReplaceAllStringFunc(body, func(match string) string {
+		name := varPattern.FindStringSubmatch(match)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return match
+	})
+}
+
+// gitDiff returns staged and unstaged changes against HEAD.
+func gitDiff(dir string) string {
+	cmd := exec.Command("git", "diff", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		// No commits yet — fall back to the working tree diff.
+		cmd = exec.Command("git", "diff")
+		cmd.Dir = dir
+		if out, err = cmd.Output(); err != nil {
+			return ""
+		}
+	}
+	diff := strings.TrimRight(string(out), "\n")
+	if len(diff) > maxGitDiff {
+		diff = diff[:maxGitDiff] + "\n... (diff truncated)"
+	}
+	return diff
+}
@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCommandTimeout = 2 * time.Minute
+	maxCommandTimeout     = 10 * time.Minute
+	// maxCommandOutput caps what is kept of each of stdout and stderr.
+	maxCommandOutput = 64 * 1024
+)
+
+func init() {
+	Register(Tool{
+		Name: "run_command",
+		Description: "Run a shell command in the project directory and return its exit code, stdout and stderr. " +
+			"Use it to build, test or inspect the project. Requires user approval unless allow-listed.",
+		Parameters: schema([]string{"command"},
+			"command", "string", "Command line, run with sh -c",
+			"timeout", "integer", "Timeout in seconds (default 120, max 600)",
+		),
+		Writes: true,
+		Run:    runCommand,
+	})
+}
+
+func runCommand(ctx context.Context, root string, args map[string]any) (string, error) {
+	command := strings.TrimSpace(StringArg(args, "command"))
+	if command == "" {
+		return "", fmt.Errorf("command is empty")
+	}
+	timeout := defaultCommandTimeout
+	if n := IntArg(args, "timeout"); n > 0 {
+		timeout = time.Duration(n) * time.Second
+	}
+	if timeout > maxCommandTimeout {
+		timeout = maxCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = root
+	cmd.WaitDelay = 2 * time.Second
+	stdout := &cappedBuffer{max: maxCommandOutput}
+	stderr := &cappedBuffer{max: maxCommandOutput}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	started := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(started).Round(time.Millisecond)
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return formatCommandResult(command, -1, elapsed, stdout, stderr) +
+			fmt.Sprintf("\n(timed out after %s)", timeout), nil
+	case ctx.Err() != nil:
+		return "", ctx.Err()
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		return "", err
+	}
+	return formatCommandResult(command, exitCode, elapsed, stdout, stderr), nil
+}
+
+func formatCommandResult(command string, exitCode int, elapsed time.Duration, stdout, stderr *cappedBuffer) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ %s\nexit code: %d (%s)\n", command, exitCode, elapsed)
+	if stdout.Len() > 0 {
+		b.WriteString("--- stdout ---\n" + stdout.String())
+	}
+	if stderr.Len() > 0 {
+		b.WriteString("--- stderr ---\n" + stderr.String())
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// ExitCode reads the exit code back out of a run_command result, or -1.
+func ExitCode(result string) int {
+	for _, line := range strings.SplitN(result, "\n", 3) {
+		var code int
+		if _, err := fmt.Sscanf(line, "exit code: %d", &code); err == nil {
+			return code
+		}
+	}
+	return -1
+}
+
+// CommandAllowed reports whether command may run without approval. Entries
+// match exactly, or by prefix when they end in "*". A prefix is matched
+// word by word, and the words after it may only be plain arguments: no
+// flags, quotes, chaining or redirects. So "go test *" approves
+// "go test ./..." but not "go test -exec 'rm -rf ~' ./..." or
+// "go test ./... && rm -rf ~".
+func CommandAllowed(command string, allowed []string) bool {
+	command = strings.TrimSpace(command)
+	words := strings.Fields(command)
+	for _, entry := range allowed {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if command == entry {
+			return true
+		}
+		prefix, ok := strings.CutSuffix(entry, "*")
+		if !ok || hasShellControl(command) {
+			continue
+		}
+		want := strings.Fields(prefix)
+		if len(want) == 0 || len(words) < len(want) || !slices.Equal(words[:len(want)], want) {
+			continue
+		}
+		if plainArgs(words[len(want):]) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasShellControl(command string) bool {
+	return strings.ContainsAny(command, ";&|<>`$()\n\\")
+}
+
+// plainArgs reports whether words are operands only, with nothing the shell
+// or the command could read as an option.
+func plainArgs(words []string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(w, "-") || strings.ContainsAny(w, `'"*?[]{}~#!=`) {
+			return false
+		}
+	}
+	return true
+}
+
+// cappedBuffer keeps the first max bytes written and counts the rest.
+type cappedBuffer struct {
+	buf     bytes.Buffer
+	max     int
+	dropped int
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	room := c.max - c.buf.Len()
+	if room < len(p) {
+		if room > 0 {
+			c.buf.Write(p[:room])
+		}
+		c.dropped += len(p) - max(room, 0)
+		return len(p), nil
+	}
+	return c.buf.Write(p)
+}
+
+func (c *cappedBuffer) Len() int { return c.buf.Len() }
+
+func (c *cappedBuffer) String() string {
+	s := c.buf.String()
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	if c.dropped > 0 {
+		s += fmt.Sprintf("... (%d more bytes)\n", c.dropped)
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+package tools
+
+import "testing"
+
+func TestCommandAllowed(t *testing.T) {
+	allowed := []string{"make", "go test *", "npm run *", "  "}
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"make", true},
+		{"  make  ", true},
+		{"make clean", false},
+		{"go test", true},
+		{"go test ./...", true},
+		{"go test ./internal/diff ./internal/policy", true},
+		{"go  test ./...", true},
+		{"go vet ./...", false},
+		{"go testify", false},
+		{"go test -run X ./...", false},
+		{"go test -exec 'rm -rf ~' ./...", false},
+		{"go test ./... && rm -rf ~", false},
+		{"go test ./...; rm -rf ~", false},
+		{"go test ./... | sh", false},
+		{"go test ./... > out", false},
+		{"go test $(rm -rf ~)", false},
+		{"go test `rm -rf ~`", false},
+		{"go test ~", false},
+		{"go test *.go", false},
+		{"go test \"a b\"", false},
+		{"npm run build", true},
+		{"npm run build --prefix /", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := CommandAllowed(tt.command, allowed); got != tt.want {
+			t.Errorf("CommandAllowed(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+	if CommandAllowed("make", nil) {
+		t.Error("an empty allow-list approved a command")
+	}
+	if !CommandAllowed("rm -rf build", []string{"rm -rf build"}) {
+		t.Error("an exact entry may contain flags")
+	}
+}
@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"dwight/internal/policy"
+)
+
+// maxGrepMatches bounds grep output before truncation kicks in.
+const maxGrepMatches = 200
+
+// skipDirs are never listed or searched.
+var skipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, "__pycache__": true}
+
+func init() {
+	Register(Tool{
+		Name:        "read_file",
+		Description: "Read a text file in the project. Optionally limit to a 1-based, inclusive line range.",
+		Parameters: schema([]string{"path"},
+			"path", "string", "File path relative to the project directory",
+			"start_line", "integer", "First line to return (optional)",
+			"end_line", "integer", "Last line to return (optional)",
+		),
+		Run: readFile,
+	})
+	Register(Tool{
+		Name:        "list_dir",
+		Description: "List the entries of a directory in the project. Directories end with a slash.",
+		Parameters: schema(nil,
+			"path", "string", "Directory relative to the project directory (default: the project root)",
+		),
+		Run: listDir,
+	})
+	Register(Tool{
+		Name:        "grep",
+		Description: "Search project files for a regular expression. Returns file:line: text for each match.",
+		Parameters: schema([]string{"pattern"},
+			"pattern", "string", "Regular expression (RE2 syntax)",
+			"path", "string", "File or directory to search (default: the project root)",
+		),
+		Run: grep,
+	})
+	Register(Tool{
+		Name:        "write_file",
+		Description: "Create or overwrite a file in the project with the given content. Requires user approval.",
+		Parameters: schema([]string{"path", "content"},
+			"path", "string", "File path relative to the project directory",
+			"content", "string", "Complete new file content",
+		),
+		Writes: true,
+		Run:    writeFile,
+	})
+}
+
+func readFile(ctx context.Context, root string, args map[string]any) (string, error) {
+	path, err := Resolve(root, StringArg(args, "path"))
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if isBinary(data) {
+		return "", fmt.Errorf("%s is a binary file", StringArg(args, "path"))
+	}
+	start, end := IntArg(args, "start_line"), IntArg(args, "end_line")
+	if start <= 0 && end <= 0 {
+		return string(data), nil
+	}
+	lines := strings.Split(string(data), "\n")
+	if start < 1 {
+		start = 1
+	}
+	if end <= 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return "", fmt.Errorf("line range %d-%d is empty (file has %d lines)", start, end, len(lines))
+	}
+	return strings.Join(lines[start-1:end], "\n"), nil
+}
+
+func listDir(ctx context.Context, root string, args map[string]any) (string, error) {
+	path, err := Resolve(root, StringArg(args, "path"))
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		if skipDirs[e.Name()] {
+			continue
+		}
+		if e.IsDir() {
+			fmt.Fprintf(&b, "%s/\n", e.Name())
+			continue
+		}
+		size := int64(0)
+		if info, err := e.Info(); err == nil {
+			size = info.Size()
+		}
+		fmt.Fprintf(&b, "%s\t%d bytes\n", e.Name(), size)
+	}
+	if b.Len() == 0 {
+		return "(empty directory)", nil
+	}
+	return b.String(), nil
+}
+
+func grep(ctx context.Context, root string, args map[string]any) (string, error) {
+	re, err := regexp.Compile(StringArg(args, "pattern"))
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %v", err)
+	}
+	start, err := Resolve(root, StringArg(args, "path"))
+	if err != nil {
+		return "", err
+	}
+	// Walk the real tree, so a working directory reached through a symlink
+	// is still descended into.
+	if root, err = policy.Resolve(root); err != nil {
+		return "", err
+	}
+	if start, err = policy.Resolve(start); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	matches := 0
+	err = filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if path != start && (skipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			if _, err := Resolve(root, path); err != nil {
+				return nil
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || isBinary(data) {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for n := 1; scanner.Scan(); n++ {
+			if re.Match(scanner.Bytes()) {
+				fmt.Fprintf(&b, "%s:%d: %s\n", filepath.ToSlash(rel), n, strings.TrimSpace(scanner.Text()))
+				if matches++; matches >= maxGrepMatches {
+					return filepath.SkipAll
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if matches == 0 {
+		return "no matches", nil
+	}
+	if matches >= maxGrepMatches {
+		fmt.Fprintf(&b, "... (stopped after %d matches)\n", maxGrepMatches)
+	}
+	return b.String(), nil
+}
+
+func writeFile(ctx context.Context, root string, args map[string]any) (string, error) {
+	path, err := Resolve(root, StringArg(args, "path"))
+	if err != nil {
+		return "", err
+	}
+	content := StringArg(args, "content")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write file: %v", err)
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(content), StringArg(args, "path")), nil
+}
+
+// isBinary treats data containing a NUL byte in its first 8KB as binary.
+func isBinary(data []byte) bool {
+	if len(data) > 8192 {
+		data = data[:8192]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
@@ -0,0 +1,166 @@
+// Package tools is the local tool set offered to models that support function
+// calling. Every tool runs against a root directory (the chat's working
+// directory) and rejects paths that escape it.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"dwight/internal/policy"
+)
+
+// maxOutput caps how much of a tool's result is sent back to the model.
+const maxOutput = 32 * 1024
+
+// Tool is one callable function.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any // JSON Schema object for the arguments
+	// Writes marks tools that change the filesystem; the UI asks for approval
+	// before running them.
+	Writes bool
+	Run    func(ctx context.Context, root string, args map[string]any) (string, error)
+}
+
+var registry = map[string]Tool{}
+
+// Register adds a tool. Later registrations replace earlier ones.
+func Register(t Tool) {
+	registry[t.Name] = t
+}
+
+func Get(name string) (Tool, bool) {
+	t, ok := registry[name]
+	return t, ok
+}
+
+// All returns the registered tools sorted by name.
+func All() []Tool {
+	out := make([]Tool, 0, len(registry))
+	for _, t := range registry {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Run executes the named tool and truncates its output to maxOutput.
+func Run(ctx context.Context, root, name string, args map[string]any) (string, error) {
+	t, ok := registry[name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", name)
+	}
+	out, err := t.Run(ctx, root, args)
+	if len(out) > maxOutput {
+		out = out[:maxOutput] + fmt.Sprintf("\n... (truncated, %d bytes total)", len(out))
+	}
+	return out, err
+}
+
+// Summary renders a call for the transcript, e.g. `read_file(path="main.go")`.
+// Long values are shortened.
+func Summary(name string, args map[string]any) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		var v string
+		if s, ok := args[k].(string); ok {
+			if strings.Contains(s, "\n") || len(s) > 60 {
+				v = fmt.Sprintf("<%d lines>", LineCount(s))
+			} else {
+				v = fmt.Sprintf("%q", s)
+			}
+		} else {
+			data, _ := json.Marshal(args[k])
+			v = string(data)
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+// Resolve maps a tool path argument onto root, rejecting anything outside it.
+// Containment is checked with symlinks resolved, so a link inside the project
+// cannot lead out of it.
+func Resolve(root, path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		path = "."
+	}
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(root, path)
+	}
+	abs = filepath.Clean(abs)
+	realRoot, err := policy.Resolve(root)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := policy.Resolve(abs)
+	if err != nil {
+		return "", err
+	}
+	if !within(realRoot, resolved) {
+		return "", fmt.Errorf("path %q is outside the project directory", path)
+	}
+	return abs, nil
+}
+
+func within(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// LineCount counts lines, not counting a trailing newline as an extra line.
+func LineCount(s string) int {
+	return strings.Count(strings.TrimSuffix(s, "\n"), "\n") + 1
+}
+
+// StringArg returns args[key] as a string, or "" when absent.
+func StringArg(args map[string]any, key string) string {
+	switch v := args[key].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// IntArg returns args[key] as an int. JSON numbers arrive as float64, but
+// some models send numbers as strings.
+func IntArg(args map[string]any, key string) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		var n int
+		fmt.Sscanf(v, "%d", &n)
+		return n
+	}
+	return 0
+}
+
+// schema builds a JSON Schema object from (name, type, description) triples.
+func schema(required []string, props ...string) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i+2 < len(props); i += 3 {
+		properties[props[i]] = map[string]any{"type": props[i+1], "description": props[i+2]}
+	}
+	if required == nil {
+		required = []string{}
+	}
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -19,6 +20,18 @@ func main() {
 		showUsage()
 		return
 	}
+	if code, ok := runSubcommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
+	fs := flag.NewFlagSet("dwight", flag.ContinueOnError)
+	continueLast := fs.Bool("continue", false, "resume the latest conversation from this repo")
+	fs.BoolVar(continueLast, "c", false, "shorthand for --continue")
+	resumeID := fs.String("resume", "", "resume the conversation with this id")
+	fs.Usage = showUsage
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
 
 	currentDir, _ := os.Getwd()
 	workContext := storage.DetectWorkContext(currentDir)
@@ -68,26 +81,80 @@ func main() {
 		chatSpinner:  sp,
 		chatMaxLines: 14,
 
+		gitMessage: newCommitMessageArea(),
+
 		editingProfile: -1,
 	}
 
+	if *continueLast || *resumeID != "" {
+		conv, err := findResumeConversation(*resumeID, workContext)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dwight: %v\n", err)
+			os.Exit(1)
+		}
+		m.viewMode = ViewChat
+		if conv != nil {
+			m.prepareLoadedConversation(conv)
+			m.startupCmd = tea.Batch(m.fetchContextWindow(), showStatus(fmt.Sprintf("Resumed: %s", conv.Title)))
+		} else {
+			m.resetChatSession()
+			m.chatState = ChatStateCheckingModel
+			m.chatTextArea.Focus()
+			m.startupCmd = tea.Batch(m.checkModel(), m.chatSpinner.Tick, showStatus("No earlier conversation here — started a new one"))
+		}
+	}
+
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// findResumeConversation loads the conversation named by id, or with an empty
+// id the most recently updated one started in the same repo (or directory).
+// It returns nil when --continue finds nothing to resume.
+func findResumeConversation(id string, wc storage.WorkContext) (*storage.Conversation, error) {
+	if id != "" {
+		return loadConversationByPrefix(id)
+	}
+	convs, err := storage.ListConversations()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range convs {
+		if storage.SameProject(c, wc) {
+			return storage.LoadConversation(c.ID)
+		}
+	}
+	return nil, nil
+}
+
 func showUsage() {
 	fmt.Println(`dwight - Terminal AI Chat & Doc Manager
 
 USAGE:
     dwight [FLAGS]
+    dwight <COMMAND> [ARGS]
 
 FLAGS:
-    -h, --help    Show this help message
+    -h, --help       Show this help message
+    -c, --continue   Reopen the latest conversation started in this repo
+    --resume <id>    Reopen a conversation by id (or unique id prefix)
+
+COMMANDS:
+    ask "question"    Stream one answer to stdout; piped stdin is appended
+                      (--profile NAME, --json, --save)
+    conv list|show|export|delete|search
+                      Script the conversation library (dwight conv --help)
+    serve [--addr HOST:PORT] [--log]
+                      OpenAI-compatible API over your profiles
+    commit-msg        Write a commit message for the staged diff
+                      (--profile NAME, --range REV, --hook, --install-hook)
+    review            Review the staged diff, findings per file by severity
+                      (--profile NAME, --range REV, --json)
 
 FEATURES:
-    • Chat with Ollama or Gemini models (streaming)
+    • Chat with Ollama, Gemini, Anthropic or OpenAI-compatible models (streaming)
     • Manage model profiles and switch between them
     • Save, load, and export conversation history (global library under your data dir)
     • Attach local files as context (RAG)
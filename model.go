@@ -4,8 +4,13 @@ import (
 	"context"
 	"time"
 
+	"dwight/internal/diff"
+	"dwight/internal/journal"
 	"dwight/internal/ollama"
+	"dwight/internal/policy"
+	"dwight/internal/provider"
 	"dwight/internal/storage"
+	"dwight/internal/templates"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
@@ -30,6 +35,9 @@ const (
 	ViewModelPull
 	ViewModelLibrary
 	ViewConfirmDialog
+	ViewChanges
+	ViewChangeset
+	ViewGit
 )
 
 type ChatState int
@@ -48,6 +56,9 @@ type ConfirmAction int
 
 const (
 	ConfirmDeleteModel ConfirmAction = iota
+	ConfirmToolCall
+	ConfirmRevert
+	ConfirmGitUnrelated
 )
 
 // =============================================================================
@@ -81,9 +92,23 @@ type StreamChunkMsg struct {
 	Duration     time.Duration
 	PromptTokens int
 	TotalTokens  int
+	ToolCalls    []storage.ToolCall
 }
 
-type streamStartedMsg struct{ ch <-chan ollama.StreamChunk }
+// toolResultMsg carries the output of the first pending tool call.
+type toolResultMsg struct {
+	output string
+	err    error
+	change *journal.Change // set when the call wrote a file
+}
+
+type streamStartedMsg struct {
+	ch <-chan provider.StreamChunk
+	// Set when older turns were folded into the rolling summary for this request.
+	summary           string
+	summarizedThrough int
+	contextNote       string
+}
 
 type ClearChatMsg struct{}
 type InterruptMsg struct{}
@@ -95,7 +120,17 @@ type ModelPullMsg struct {
 }
 
 type installedModelsMsg struct {
-	models []ollama.Model
+	models []provider.ModelInfo
+}
+
+type contextWindowMsg struct {
+	model string
+	size  int
+}
+
+type profileModelsMsg struct {
+	profile string
+	models  []provider.ModelInfo
 }
 
 // =============================================================================
@@ -103,12 +138,19 @@ type installedModelsMsg struct {
 // =============================================================================
 
 type ChatMessage struct {
+	ID           string
+	ParentID     string
 	Role         string
 	Content      string
 	Timestamp    time.Time
 	Duration     time.Duration
 	PromptTokens int
 	TotalTokens  int
+	ToolCalls    []storage.ToolCall // assistant turn: calls the model requested
+	ToolName     string             // role "tool": which call this result answers
+	// Position among siblings at a fork (0 of 1 when there is no fork)
+	branchIdx   int
+	branchCount int
 	// Render cache
 	formattedLines []string
 	lastWidth      int
@@ -161,19 +203,42 @@ type model struct {
 	chatMaxLines     int
 	chatStreaming    bool
 	chatStreamBuffer string
-	chatStreamCh     <-chan ollama.StreamChunk
+	chatStreamCh     <-chan provider.StreamChunk
+	chatContextSize  int                // tokens; 0 until the provider reports it
 	cancelChat       context.CancelFunc // cancels in-flight generation
 
+	// Context budget — chatMessages[:chatSummaryThrough] are represented by chatSummary
+	chatSummary        string
+	chatSummaryThrough int
+	chatContextNote    string // header hint when history was summarized or dropped
+
 	// Copy mode — navigate messages, yank to clipboard
 	chatCopyMode     bool
 	chatCopyIdx      int // index into chatMessages (-1 = last)
 	chatCopySelected map[int]bool
 
+	// Message tree — chatMessages is the active branch, chatBranches the rest
+	chatBranches []ChatMessage
+	chatEditID   string // user message being edited; resending forks beside it
+
 	// Conversation management
 	currentConversation *storage.Conversation
 	conversations       []storage.ConversationMeta
 	selectedConv        int
 
+	// Prompt templates — picker, then one prompt per {{variable}}
+	showTemplatePicker bool
+	templateList       []templates.Template
+	templateCursor     int
+	templateActive     *templates.Template
+	templateVars       []string
+	templateValues     map[string]string
+	templateInput      textinput.Model
+	templateSelection  string   // {{selection}} captured when the picker opened
+	chatTemperature    *float64 // session override of the profile temperature
+	chatProfile        string   // session override of the current profile, by name
+	chatSystemPrompt   string   // session override of the profile system prompt (/system)
+
 	// RAG — attached resource paths
 	attachedResources  []string
 	showResourcePicker bool
@@ -186,10 +251,11 @@ type model struct {
 	modelPullName   string
 	modelPullStatus string
 	modelPullError  error
+	profileModels   []provider.ModelInfo // models served by the selected profile's endpoint
 
 	// Model library
 	libraryModels    []ollama.LibraryModel
-	installedModels  []ollama.Model
+	installedModels  []provider.ModelInfo
 	librarySelection int
 	libraryFilter    string
 
@@ -199,20 +265,62 @@ type model struct {
 	// Conversation export
 	exportFormat string
 
-	// @ autocomplete
+	// @ autocomplete (also drives / command completion)
 	showAtComplete   bool
+	atCompletePrefix string   // "@" for files, "/" for slash commands
 	atCompleteFiles  []string // filtered results
 	atCompleteCursor int
 	atCompleteFilter string
 	fileCache        []string // cached project files
 	fileCacheDir     string   // dir the cache was built for
 
+	// Tool calls — pending calls of the current assistant turn, run in order
+	chatStreamToolCalls []storage.ToolCall
+	pendingToolCalls    []storage.ToolCall
+	toolRounds          int // model turns with tool calls since the last user message
+
 	// Code block review (accept/refine/reject)
-	codeBlocks  []CodeBlock
-	reviewIndex int
+	codeBlocks   []CodeBlock
+	reviewIndex  int
+	reviewScroll int // first diff line shown in the review bar
+
+	// Post-apply hooks — run on the files a review or changeset wrote
+	reviewWritten []string // files accepted so far in this review
+	hooksRunning  bool
+	hookRunID     int                // stamps each run; results of older runs are dropped
+	cancelHooks   context.CancelFunc // stops the running hooks
+	hookFailures  string             // output of failed hooks, sent to the model on alt+f
+
+	// Changeset — all files of a multi-file response, applied together
+	changesetSelected map[int]bool // codeBlocks index -> apply it
+	changesetCursor   int          // position among actionableBlocks()
+	changesetScroll   int          // first diff line of the preview
+
+	// Change journal — every file written this run, revertible from /changes
+	journal       *journal.Journal
+	changesCursor int
+	pendingRevert revertTarget // retried with force once the user confirms
+
+	// Git — status, stage and commit this session's files (/git)
+	gitBranch        string
+	gitFiles         []string // session files still changed, relative to the git root
+	gitStatus        string   // git status --short of gitFiles
+	gitDiff          string   // gitFiles against HEAD
+	gitUnrelated     []string // staged paths Dwight did not write
+	gitScroll        int
+	gitMessage       textarea.Model
+	gitEditing       bool
+	gitGenerating    bool
+	gitCancel        context.CancelFunc // stops the message being generated
+	gitMessageID     int                // stamps each generation; older replies are dropped
+	gitRunning       string             // "stage" or "commit" while git runs
+	pendingGitAction string             // "stage" or "commit", run once the user confirms
 
 	// Dialogs
 	confirmDialog *ConfirmDialog
+
+	// Startup — extra command run by Init (e.g. --continue opening a chat)
+	startupCmd tea.Cmd
 }
 
 // MenuItem represents a menu option.
@@ -226,6 +334,12 @@ type CodeBlock struct {
 	Language string
 	Path     string // detected file path (may be empty)
 	Content  string
+	Edit     bool          // Content is the file after applying an edit block, not the block itself
+	Err      error         // why an edit block could not be applied
+	Target   policy.Target // where Path resolves, once it passed the write policy
+	Denied   error         // why the write policy refuses Path
+	Hunks    []diff.Hunk   // Content against the file on disk, computed once for review
+	NewFile  bool          // nothing exists at Target yet
 }
 
 // =============================================================================
@@ -252,9 +366,23 @@ func (m *model) safeHeight() int {
 }
 
 func (m *model) currentProfile() storage.ModelProfile {
+	if idx := m.profileIndex(); idx >= 0 && idx < len(m.modelConfig.Profiles) {
+		return m.modelConfig.Profiles[idx]
+	}
 	return m.modelConfig.Current()
 }
 
+// profileIndex is the profile this chat uses: a template's pinned profile
+// for the session, else the saved default.
+func (m *model) profileIndex() int {
+	if m.chatProfile != "" {
+		if idx := m.modelConfig.Index(m.chatProfile); idx >= 0 {
+			return idx
+		}
+	}
+	return m.modelConfig.CurrentProfile
+}
+
 func (m *model) currentProvider() string {
 	return storage.NormalizeProvider(m.currentProfile().Provider)
 }
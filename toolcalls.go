@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"dwight/internal/diff"
+	"dwight/internal/journal"
+	"dwight/internal/provider"
+	"dwight/internal/storage"
+	s "dwight/internal/styles"
+	"dwight/internal/tools"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// =============================================================================
+// Tool calls
+// =============================================================================
+
+// maxToolRounds stops a model that keeps calling tools without answering.
+const maxToolRounds = 10
+
+// toolSystemNote tells the model where its tools operate.
+func toolSystemNote(dir string) string {
+	return fmt.Sprintf("You can call tools to inspect and edit files in the project directory %s. "+
+		"Paths are relative to it. Read files before changing them; write_file replaces the whole file. "+
+		"Use run_command to build or test your changes and fix any failures it reports.", dir)
+}
+
+// providerTools declares the registry to a backend.
+func providerTools() []provider.Tool {
+	all := tools.All()
+	out := make([]provider.Tool, len(all))
+	for i, t := range all {
+		out[i] = provider.Tool{Name: t.Name, Description: t.Description, Parameters: t.Parameters}
+	}
+	return out
+}
+
+// toProviderMessage converts a transcript entry for a chat request.
+func toProviderMessage(msg ChatMessage, baseDir string) provider.Message {
+	content := msg.Content
+	if msg.Role == "user" {
+		content = resolveAtReferences(content, baseDir)
+	}
+	return provider.Message{Role: msg.Role, Content: content, ToolCalls: msg.ToolCalls, ToolName: msg.ToolName}
+}
+
+// flattenToolMessages rewrites tool traffic as plain text for backends without
+// function calling, e.g. after switching profiles mid-conversation.
+func flattenToolMessages(msgs []provider.Message) []provider.Message {
+	out := make([]provider.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		switch {
+		case msg.Role == "tool":
+			msg = provider.Message{Role: "user", Content: fmt.Sprintf("Result of %s:\n%s", msg.ToolName, msg.Content)}
+		case len(msg.ToolCalls) > 0:
+			var calls []string
+			for _, call := range msg.ToolCalls {
+				calls = append(calls, "Called "+tools.Summary(call.Name, call.Arguments))
+			}
+			content := strings.TrimSpace(msg.Content + "\n" + strings.Join(calls, "\n"))
+			msg = provider.Message{Role: msg.Role, Content: content}
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+// startToolCalls queues the calls of the assistant turn just received.
+func (m model) startToolCalls(calls []storage.ToolCall) (tea.Model, tea.Cmd) {
+	m.toolRounds++
+	if m.toolRounds > maxToolRounds {
+		m.pendingToolCalls = calls
+		m.stopToolCalls()
+		m.updateChatLines()
+		m.chatState = ChatStateReady
+		m.chatTextArea.Focus()
+		return m, showStatus(fmt.Sprintf("Stopped after %d rounds of tool calls", maxToolRounds))
+	}
+	m.pendingToolCalls = calls
+	m.chatState = ChatStateLoading
+	return m.nextToolCall()
+}
+
+// nextToolCall runs the first pending call, asking first if it writes. Once
+// every call has a result the model is asked to continue.
+func (m model) nextToolCall() (tea.Model, tea.Cmd) {
+	if len(m.pendingToolCalls) == 0 {
+		m.updateChatLines()
+		return m, tea.Batch(m.sendChat(), m.chatSpinner.Tick)
+	}
+	call := m.pendingToolCalls[0]
+	if command, ok := m.pendingCommand(); ok && tools.CommandAllowed(command, m.config.Project(m.projectRoot()).AllowedCommands) {
+		return m, tea.Batch(m.runToolCall(call), m.chatSpinner.Tick, showStatus("Auto-approved: "+command))
+	}
+	if call.Name == "write_file" {
+		if _, err := m.toolWritePolicy().Check(tools.StringArg(call.Arguments, "path")); err != nil {
+			next, cmd := m.finishToolCall("error: write refused: " + err.Error())
+			return next, tea.Batch(cmd, showStatus("Cannot write: "+err.Error()))
+		}
+	}
+	if tool, ok := tools.Get(call.Name); ok && tool.Writes {
+		m.confirmDialog = &ConfirmDialog{
+			Action:       ConfirmToolCall,
+			Message:      m.toolApprovalMessage(call),
+			PreviousView: ViewChat,
+		}
+		m.viewMode = ViewConfirmDialog
+		return m, nil
+	}
+	return m, tea.Batch(m.runToolCall(call), m.chatSpinner.Tick)
+}
+
+func (m *model) runToolCall(call storage.ToolCall) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelChat = cancel
+	dir := m.currentDir
+	args := call.Arguments
+	target := ""
+	if call.Name == "write_file" {
+		// Write and journal through the resolved path, as accepted blocks are,
+		// so each file has one entry however it was reached.
+		t, err := m.toolWritePolicy().Check(tools.StringArg(args, "path"))
+		if err != nil {
+			return func() tea.Msg { return toolResultMsg{err: fmt.Errorf("write refused: %v", err)} }
+		}
+		target = t.Path
+		args = maps.Clone(args)
+		args["path"] = target
+	}
+	return func() tea.Msg {
+		// Snapshot a file before write_file replaces it so the write can be undone.
+		var change *journal.Change
+		if target != "" {
+			if c, err := journal.Capture(target); err == nil {
+				change = &c
+			}
+		}
+		out, err := tools.Run(ctx, dir, call.Name, args)
+		if err != nil {
+			change = nil
+		} else if change != nil {
+			change.Content = tools.StringArg(call.Arguments, "content")
+		}
+		return toolResultMsg{output: out, err: err, change: change}
+	}
+}
+
+// finishToolCall records the result of the first pending call and moves on.
+func (m model) finishToolCall(output string) (tea.Model, tea.Cmd) {
+	if len(m.pendingToolCalls) == 0 {
+		return m, nil
+	}
+	call := m.pendingToolCalls[0]
+	m.pendingToolCalls = m.pendingToolCalls[1:]
+	m.appendChatMessage(ChatMessage{
+		Role: "tool", ToolName: call.Name, Content: output, Timestamp: time.Now(),
+	})
+	if tool, ok := tools.Get(call.Name); ok && tool.Writes {
+		m.fileCache = nil
+	}
+	return m.nextToolCall()
+}
+
+// stopToolCalls answers every pending call with a placeholder result, so the
+// assistant turn that made them never goes back to the model unanswered.
+func (m *model) stopToolCalls() {
+	for _, call := range m.pendingToolCalls {
+		m.appendChatMessage(ChatMessage{
+			Role: "tool", ToolName: call.Name, Content: "not run (stopped)", Timestamp: time.Now(),
+		})
+	}
+	m.pendingToolCalls = nil
+}
+
+// pendingCommand returns the command line of a pending run_command call.
+func (m *model) pendingCommand() (string, bool) {
+	if len(m.pendingToolCalls) == 0 || m.pendingToolCalls[0].Name != "run_command" {
+		return "", false
+	}
+	return strings.TrimSpace(tools.StringArg(m.pendingToolCalls[0].Arguments, "command")), true
+}
+
+// projectRoot keys per-project config: the git root, else the working directory.
+func (m *model) projectRoot() string {
+	if m.workContext.GitRoot != "" {
+		return m.workContext.GitRoot
+	}
+	return m.currentDir
+}
+
+// allowCommand adds command to the project's auto-approve list.
+func (m *model) allowCommand(command string) {
+	root := m.projectRoot()
+	p := m.config.Project(root)
+	if !containsString(p.AllowedCommands, command) {
+		p.AllowedCommands = append(p.AllowedCommands, command)
+	}
+	m.config.SetProject(root, p)
+	storage.SaveConfig(m.config)
+}
+
+// toolApprovalMessage describes a call for the confirm dialog.
+func (m *model) toolApprovalMessage(call storage.ToolCall) string {
+	var b strings.Builder
+	if command, ok := m.pendingCommand(); ok {
+		fmt.Fprintf(&b, "The model wants to run a command in %s:\n\n  $ %s\n", m.currentDir, command)
+		if t := tools.IntArg(call.Arguments, "timeout"); t > 0 {
+			fmt.Fprintf(&b, "  (timeout %ds)\n", t)
+		}
+		b.WriteString("\nRun it? (a: always allow this command in this project)")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "The model wants to run:\n\n  %s\n", tools.Summary(call.Name, call.Arguments))
+	path := tools.StringArg(call.Arguments, "path")
+	if call.Name == "write_file" && path != "" {
+		target, err := m.toolWritePolicy().Check(path)
+		if err != nil {
+			fmt.Fprintf(&b, "\n%v\n", err)
+			return b.String()
+		}
+		if target.NewDir != "" {
+			fmt.Fprintf(&b, "\n⚠ creates new directory %s/\n", target.NewDir)
+		}
+		if target.Ignored {
+			fmt.Fprintf(&b, "\n⚠ %s is ignored by git\n", target.Rel)
+		}
+		content := tools.StringArg(call.Arguments, "content")
+		old, err := os.ReadFile(target.Path)
+		if err != nil {
+			fmt.Fprintf(&b, "\nNew file %s (%d lines)\n", path, tools.LineCount(content))
+		} else {
+			lines := diff.Unified(path, diff.Compute(string(old), content, diff.DefaultContext))
+			if len(lines) > 20 {
+				lines = append(lines[:20], fmt.Sprintf("... (%d more lines)", len(lines)-20))
+			}
+			b.WriteString("\n" + strings.Join(lines, "\n") + "\n")
+		}
+	}
+	b.WriteString("\nAllow it?")
+	return b.String()
+}
+
+// formatToolCalls renders the calls an assistant turn made.
+func formatToolCalls(calls []storage.ToolCall, width int) []string {
+	var lines []string
+	for _, call := range calls {
+		for _, line := range wrapText("→ "+tools.Summary(call.Name, call.Arguments), width-2) {
+			lines = append(lines, "  "+s.Warning.Render(line))
+		}
+	}
+	return lines
+}
+
+// formatToolResult renders a tool message, showing only the first lines.
+// Command results get their exit code in the header and a longer preview.
+func formatToolResult(msg *ChatMessage, width int) []string {
+	maxLines := 6
+	label := msg.ToolName
+	if msg.Role == "hook" {
+		label = "post-apply hook"
+	}
+	lines := []string{s.Dim.Render(fmt.Sprintf("  ← %s", label))}
+	if msg.ToolName == "run_command" || msg.Role == "hook" {
+		maxLines = 12
+		switch code := tools.ExitCode(msg.Content); {
+		case code == 0:
+			lines[0] += s.Success.Render(" · exit 0")
+		case code > 0:
+			lines[0] += s.Error.Render(fmt.Sprintf(" · exit %d", code))
+		}
+	}
+	body := strings.Split(strings.TrimRight(msg.Content, "\n"), "\n")
+	shown := body
+	if len(shown) > maxLines {
+		shown = shown[:maxLines]
+	}
+	for _, line := range shown {
+		lines = append(lines, s.Dim.Render("    "+truncateStr(line, width-4)))
+	}
+	if len(body) > maxLines {
+		lines = append(lines, s.Dim.Render(fmt.Sprintf("    ... %d more lines", len(body)-maxLines)))
+	}
+	return append(lines, "")
+}
@@ -8,9 +8,12 @@ import (
 	"strings"
 	"time"
 
-	"dwight/internal/gemini"
+	"dwight/internal/budget"
+	"dwight/internal/journal"
 	"dwight/internal/ollama"
+	"dwight/internal/provider"
 	"dwight/internal/storage"
+	"dwight/internal/templates"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -18,7 +21,7 @@ import (
 )
 
 func (m model) Init() tea.Cmd {
-	return tickCmd()
+	return tea.Batch(tickCmd(), m.startupCmd)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -47,7 +50,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, tea.Quit
 		}
-		if msg.String() == "?" {
+		if msg.String() == "?" && !(m.viewMode == ViewGit && m.gitEditing) {
 			m.showHelp = !m.showHelp
 			return m, nil
 		}
@@ -78,6 +81,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateModelLibrary(msg)
 		case ViewConfirmDialog:
 			return m.updateConfirmDialog(msg)
+		case ViewChanges:
+			return m.updateChanges(msg)
+		case ViewChangeset:
+			return m.updateChangeset(msg)
+		case ViewGit:
+			return m.updateGit(msg)
 		}
 
 	case spinner.TickMsg:
@@ -104,6 +113,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.chatState = ChatStateReady
 			m.chatTextArea.Focus()
+			m.chatContextSize = 0
+			return m, m.fetchContextWindow()
+		}
+		return m, nil
+
+	case hooksDoneMsg:
+		return m.finishHooks(msg)
+
+	case gitActionMsg:
+		return m.finishGitAction(msg)
+
+	case commitMessageMsg:
+		if msg.id != m.gitMessageID || !m.gitGenerating {
+			return m, nil // cancelled
+		}
+		m.gitGenerating = false
+		m.gitCancel = nil
+		if msg.err != nil {
+			return m, showStatus(fmt.Sprintf("Failed to generate a commit message: %v", msg.err))
+		}
+		m.gitMessage.SetValue(msg.message)
+		return m, showStatus("Message ready: e to edit, c to commit")
+
+	case contextWindowMsg:
+		if msg.model == m.currentProfile().Model {
+			m.chatContextSize = msg.size
 		}
 		return m, nil
 
@@ -111,17 +146,22 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.Err != nil {
 			m.chatErr = msg.Err
 			m.chatState = ChatStateError
+			m.reattachReply()
 		} else {
-			m.chatMessages = append(m.chatMessages, ChatMessage{
+			m.appendChatMessage(ChatMessage{
 				Role: "assistant", Content: msg.Content, Timestamp: time.Now(),
 				Duration: msg.Duration, PromptTokens: msg.PromptTokens, TotalTokens: msg.TotalTokens,
 			})
 			// Check for code blocks — enter review mode if found
-			blocks := extractCodeBlocks(msg.Content)
+			blocks := m.resolveCodeBlocks(extractCodeBlocks(msg.Content))
 			if len(blocks) > 0 && hasActionableBlocks(blocks) {
 				m.codeBlocks = blocks
 				m.reviewIndex = 0
+				m.reviewScroll = 0
 				m.chatState = ChatStateReview
+				if len(m.actionableBlocks()) > 1 {
+					m.openChangeset()
+				}
 			} else {
 				m.chatState = ChatStateReady
 				m.chatTextArea.Focus()
@@ -131,6 +171,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case streamStartedMsg:
+		if msg.summary != "" {
+			m.chatSummary = msg.summary
+			m.chatSummaryThrough = msg.summarizedThrough
+		}
+		if msg.contextNote != "" {
+			m.chatContextNote = msg.contextNote
+		}
 		m.chatStreamCh = msg.ch
 		m.chatStreaming = true
 		m.chatState = ChatStateLoading
@@ -145,24 +192,38 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.chatState = ChatStateError
 			m.chatStreaming = false
 			m.chatStreamBuffer = ""
+			m.chatStreamToolCalls = nil
+			m.reattachReply()
 			m.updateChatLines()
 			return m, nil
 		}
+		m.chatStreamToolCalls = append(m.chatStreamToolCalls, msg.ToolCalls...)
 		if msg.Done {
-			if m.chatStreamBuffer != "" {
-				m.chatMessages = append(m.chatMessages, ChatMessage{
-					Role: "assistant", Content: m.chatStreamBuffer,
+			calls := m.chatStreamToolCalls
+			m.chatStreamToolCalls = nil
+			if m.chatStreamBuffer != "" || len(calls) > 0 {
+				m.appendChatMessage(ChatMessage{
+					Role: "assistant", Content: m.chatStreamBuffer, ToolCalls: calls,
 					Duration: msg.Duration, PromptTokens: msg.PromptTokens, TotalTokens: msg.TotalTokens,
 				})
 				m.chatStreamBuffer = ""
 			}
+			if len(calls) > 0 {
+				m.chatStreaming = false
+				m.updateChatLines()
+				return m.startToolCalls(calls)
+			}
 			// Check for code blocks — enter review mode if found
 			content := m.chatMessages[len(m.chatMessages)-1].Content
-			blocks := extractCodeBlocks(content)
+			blocks := m.resolveCodeBlocks(extractCodeBlocks(content))
 			if len(blocks) > 0 && hasActionableBlocks(blocks) {
 				m.codeBlocks = blocks
 				m.reviewIndex = 0
+				m.reviewScroll = 0
 				m.chatState = ChatStateReview
+				if len(m.actionableBlocks()) > 1 {
+					m.openChangeset()
+				}
 			} else {
 				m.chatState = ChatStateReady
 				m.chatTextArea.Focus()
@@ -175,6 +236,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.updateChatLines()
 		return m, listenForChunk(m.chatStreamCh)
 
+	case toolResultMsg:
+		if msg.change != nil {
+			msg.change.ConversationID = m.conversationID()
+			m.sessionJournal().Record(*msg.change)
+		}
+		if len(m.pendingToolCalls) == 0 || m.chatState != ChatStateLoading {
+			return m, nil // interrupted
+		}
+		output := msg.output
+		if msg.err != nil {
+			output = strings.TrimSpace(output + "\nerror: " + msg.err.Error())
+		}
+		return m.finishToolCall(output)
+
 	case ClearChatMsg:
 		m.resetChatSession()
 		m.chatState = ChatStateReady
@@ -186,6 +261,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.chatState = ChatStateReady
 		m.chatStreaming = false
 		m.chatStreamBuffer = ""
+		m.chatStreamToolCalls = nil
+		m.stopToolCalls()
+		m.reattachReply()
 		m.chatTextArea.Focus()
 		m.updateChatLines()
 		return m, showStatus("Interrupted")
@@ -202,6 +280,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case installedModelsMsg:
 		m.installedModels = msg.models
 		return m, showStatus(fmt.Sprintf("Refreshed: %d models installed", len(msg.models)))
+
+	case profileModelsMsg:
+		m.profileModels = msg.models
+		return m, showStatus(fmt.Sprintf("%s: %d models available", msg.profile, len(msg.models)))
 	}
 
 	return m, nil
@@ -267,23 +349,46 @@ func (m model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.updateResourcePicker(msg)
 	}
 
+	// Template picker sub-mode
+	if m.showTemplatePicker {
+		return m.updateTemplatePicker(msg)
+	}
+
 	// Handle review mode first (before main switch to avoid duplicate cases)
 	if m.chatState == ChatStateReview {
 		switch msg.String() {
 		case "a":
 			return m.acceptCodeBlock()
 		case "r":
-			m.chatState = ChatStateReady
-			m.chatTextArea.Focus()
-			m.chatTextArea.SetValue("refine this: ")
+			prompt := "refine this: "
+			if block, ok := m.currentReviewBlock(); ok && block.Err != nil {
+				prompt = editFailurePrompt(block)
+			}
+			hooks := m.endReview()
+			m.chatTextArea.SetValue(prompt)
+			return m, hooks
+		case "c":
+			if len(m.actionableBlocks()) > 1 {
+				m.openChangeset()
+			}
+			return m, nil
+		case "j", "down":
+			m.scrollReview(1)
+			return m, nil
+		case "k", "up":
+			m.scrollReview(-1)
+			return m, nil
+		case "pgdown", "ctrl+d":
+			m.scrollReview(reviewDiffHeight)
+			return m, nil
+		case "pgup", "ctrl+u":
+			m.scrollReview(-reviewDiffHeight)
 			return m, nil
 		case "n", "esc":
 			m.reviewIndex++
+			m.reviewScroll = 0
 			if m.reviewIndex >= len(m.codeBlocks) || !hasMoreActionableBlocks(m.codeBlocks, m.reviewIndex) {
-				m.chatState = ChatStateReady
-				m.codeBlocks = nil
-				m.reviewIndex = 0
-				m.chatTextArea.Focus()
+				return m, m.endReview()
 			}
 			return m, nil
 		}
@@ -305,12 +410,16 @@ func (m model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.chatState = ChatStateReady
 			m.chatStreaming = false
 			m.chatStreamBuffer = ""
+			m.chatStreamToolCalls = nil
+			m.stopToolCalls()
+			m.reattachReply()
 			m.chatTextArea.Focus()
 			m.updateChatLines()
 			return m, showStatus("Interrupted")
 		}
 		if strings.TrimSpace(m.chatTextArea.Value()) != "" {
 			m.chatTextArea.Reset()
+			m.chatEditID = ""
 			m.showAtComplete = false
 			m.atCompleteFiles = nil
 			m.atCompleteCursor = 0
@@ -335,10 +444,22 @@ func (m model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.chatState = ChatStateReady
 			m.chatStreaming = false
 			m.chatStreamBuffer = ""
+			m.chatStreamToolCalls = nil
+			m.stopToolCalls()
+			m.reattachReply()
 			m.chatTextArea.Focus()
 			m.updateChatLines()
 			return m, showStatus("Interrupted")
 		}
+		if m.chatEditID != "" {
+			m.chatEditID = ""
+			m.chatTextArea.Reset()
+			return m, showStatus("Edit cancelled")
+		}
+		if m.hooksRunning {
+			m.stopHooks()
+			return m, showStatus("Hooks cancelled")
+		}
 		// Save and exit to menu
 		if len(m.chatMessages) > 0 {
 			m.saveCurrentChat()
@@ -368,6 +489,11 @@ func (m model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+	case "ctrl+t":
+		if m.chatState == ChatStateReady && !m.chatStreaming {
+			return m.openTemplatePicker()
+		}
+
 	case "ctrl+n":
 		if m.chatState == ChatStateReady {
 			if len(m.chatMessages) > 0 {
@@ -394,9 +520,43 @@ func (m model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, m.exportActiveConversation("markdown")
 		}
 
+	case "alt+r":
+		return m.regenerateReply()
+
+	case "alt+u":
+		if m.chatState == ChatStateLoading || m.chatStreaming {
+			return m, nil
+		}
+		return m.revert(revertTarget{undo: true}, false)
+
+	case "alt+f":
+		if m.chatState != ChatStateReady || m.chatStreaming {
+			return m, nil
+		}
+		return m.sendHookFailures()
+
+	case "alt+h", "alt+l":
+		// Hook output follows the reply it checked; flip the reply itself.
+		last := len(m.chatMessages) - 1
+		for last >= 0 && m.chatMessages[last].Role == "hook" {
+			last--
+		}
+		if m.chatState == ChatStateReady && !m.chatStreaming && last >= 0 && m.chatMessages[last].Role == "assistant" {
+			delta := 1
+			if msg.String() == "alt+h" {
+				delta = -1
+			}
+			if m.switchChatBranch(last, delta) {
+				m.updateChatLines()
+				return m, nil
+			}
+			return m, showStatus("No other versions of this reply")
+		}
+
 	case "alt+.":
 		if m.chatState == ChatStateReady && !m.chatStreaming {
-			m.modelConfig.CurrentProfile = (m.modelConfig.CurrentProfile + 1) % len(m.modelConfig.Profiles)
+			m.modelConfig.CurrentProfile = (m.profileIndex() + 1) % len(m.modelConfig.Profiles)
+			m.chatProfile = ""
 			storage.SaveModelConfig(m.modelConfig)
 			m.chatState = ChatStateCheckingModel
 			m.updateChatLines()
@@ -405,7 +565,8 @@ func (m model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "alt+,":
 		if m.chatState == ChatStateReady && !m.chatStreaming {
-			m.modelConfig.CurrentProfile = (m.modelConfig.CurrentProfile - 1 + len(m.modelConfig.Profiles)) % len(m.modelConfig.Profiles)
+			m.modelConfig.CurrentProfile = (m.profileIndex() - 1 + len(m.modelConfig.Profiles)) % len(m.modelConfig.Profiles)
+			m.chatProfile = ""
 			storage.SaveModelConfig(m.modelConfig)
 			m.chatState = ChatStateCheckingModel
 			m.updateChatLines()
@@ -431,14 +592,26 @@ func (m model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "enter":
 		if m.chatState == ChatStateReady && strings.TrimSpace(m.chatTextArea.Value()) != "" {
 			userMsg := strings.TrimSpace(m.chatTextArea.Value())
-			m.chatMessages = append(m.chatMessages, ChatMessage{
+			if name, args, ok := parseSlashCommand(userMsg); ok {
+				cmd := m.runSlashCommand(name, args)
+				return m, cmd
+			}
+			userMsg = strings.TrimPrefix(userMsg, "/") // "//text" sends "/text"
+			if m.chatEditID != "" {
+				// Resending an edited message starts a sibling branch at that point.
+				if idx := m.chatMessageIndex(m.chatEditID); idx >= 0 {
+					m.forkAt(idx)
+				}
+				m.chatEditID = ""
+			}
+			m.appendChatMessage(ChatMessage{
 				Role: "user", Content: userMsg, Timestamp: time.Now(),
 			})
 			m.chatTextArea.Reset()
 			m.chatState = ChatStateLoading
 			m.updateChatLines()
 			return m, tea.Batch(
-				m.sendChat(userMsg),
+				m.sendChat(),
 				m.chatSpinner.Tick,
 			)
 		}
@@ -458,17 +631,14 @@ func (m model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.updateChatLines()
 		}
 
-		// Detect @ typed — trigger autocomplete
+		// Detect @ typed (or / on an empty draft) — trigger autocomplete
 		val := m.chatTextArea.Value()
-		if strings.HasSuffix(val, "@") {
+		if strings.HasSuffix(val, "@") || val == "/" {
 			m.showAtComplete = true
+			m.atCompletePrefix = val[len(val)-1:]
 			m.atCompleteFilter = ""
 			m.atCompleteCursor = 0
-			allFiles := m.scanProjectFiles()
-			m.atCompleteFiles = fuzzyMatch(allFiles, "")
-			if len(m.atCompleteFiles) > 20 {
-				m.atCompleteFiles = m.atCompleteFiles[:20]
-			}
+			m.atCompleteFiles = m.atCompleteCandidates()
 		}
 
 		return m, cmd
@@ -495,9 +665,9 @@ func (m model) updateAtComplete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			// Replace the @filter with @selected-path in textarea
 			val := m.chatTextArea.Value()
 			// Find the last @ and replace everything after it
-			lastAt := strings.LastIndex(val, "@")
+			lastAt := strings.LastIndex(val, m.atCompletePrefix)
 			if lastAt >= 0 {
-				m.chatTextArea.SetValue(val[:lastAt] + "@" + selected + " ")
+				m.chatTextArea.SetValue(val[:lastAt] + m.atCompletePrefix + selected + " ")
 			}
 			m.showAtComplete = false
 		}
@@ -506,7 +676,7 @@ func (m model) updateAtComplete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.atCompleteFilter == "" {
 			// Remove the @ from textarea too
 			val := m.chatTextArea.Value()
-			if strings.HasSuffix(val, "@") {
+			if strings.HasSuffix(val, m.atCompletePrefix) {
 				m.chatTextArea.SetValue(val[:len(val)-1])
 			}
 			m.showAtComplete = false
@@ -514,39 +684,56 @@ func (m model) updateAtComplete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		m.atCompleteFilter = m.atCompleteFilter[:len(m.atCompleteFilter)-1]
 		m.atCompleteCursor = 0
-		allFiles := m.scanProjectFiles()
-		m.atCompleteFiles = fuzzyMatch(allFiles, m.atCompleteFilter)
-		if len(m.atCompleteFiles) > 20 {
-			m.atCompleteFiles = m.atCompleteFiles[:20]
-		}
+		m.atCompleteFiles = m.atCompleteCandidates()
 		// Update textarea to reflect filter
 		val := m.chatTextArea.Value()
-		lastAt := strings.LastIndex(val, "@")
+		lastAt := strings.LastIndex(val, m.atCompletePrefix)
 		if lastAt >= 0 {
-			m.chatTextArea.SetValue(val[:lastAt] + "@" + m.atCompleteFilter)
+			m.chatTextArea.SetValue(val[:lastAt] + m.atCompletePrefix + m.atCompleteFilter)
 		}
 	default:
 		// Typing characters — add to filter
 		key := msg.String()
+		if key == " " && m.atCompletePrefix == "/" {
+			// A space ends the command name; keep typing arguments in the composer.
+			m.chatTextArea.SetValue(m.chatTextArea.Value() + " ")
+			m.showAtComplete = false
+			return m, nil
+		}
 		if len(key) == 1 {
 			m.atCompleteFilter += key
 			m.atCompleteCursor = 0
-			allFiles := m.scanProjectFiles()
-			m.atCompleteFiles = fuzzyMatch(allFiles, m.atCompleteFilter)
-			if len(m.atCompleteFiles) > 20 {
-				m.atCompleteFiles = m.atCompleteFiles[:20]
-			}
+			m.atCompleteFiles = m.atCompleteCandidates()
 			// Update textarea to reflect filter
 			val := m.chatTextArea.Value()
-			lastAt := strings.LastIndex(val, "@")
+			lastAt := strings.LastIndex(val, m.atCompletePrefix)
 			if lastAt >= 0 {
-				m.chatTextArea.SetValue(val[:lastAt] + "@" + m.atCompleteFilter)
+				m.chatTextArea.SetValue(val[:lastAt] + m.atCompletePrefix + m.atCompleteFilter)
 			}
 		}
 	}
 	return m, nil
 }
 
+// atCompleteCandidates returns matches for the current filter: project files
+// after "@", registered commands after "/".
+func (m *model) atCompleteCandidates() []string {
+	if m.atCompletePrefix == "/" {
+		var names []string
+		for _, name := range commandNames() {
+			if strings.HasPrefix(name, strings.ToLower(m.atCompleteFilter)) {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	files := fuzzyMatch(m.scanProjectFiles(), m.atCompleteFilter)
+	if len(files) > 20 {
+		files = files[:20]
+	}
+	return files
+}
+
 func (m model) updateChatCopyMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "esc", "q":
@@ -564,6 +751,38 @@ func (m model) updateChatCopyMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.chatCopyIdx++
 			m.updateChatLines()
 		}
+	case "e":
+		if m.chatCopyIdx < 0 || m.chatCopyIdx >= len(m.chatMessages) {
+			return m, nil
+		}
+		if m.chatState != ChatStateReady || m.chatStreaming {
+			return m, showStatus("Wait for the response to finish")
+		}
+		target := m.chatMessages[m.chatCopyIdx]
+		if target.Role != "user" {
+			return m, showStatus("Only your own messages can be edited")
+		}
+		m.chatEditID = target.ID
+		m.chatCopyMode = false
+		m.chatCopySelected = nil
+		m.chatTextArea.SetValue(target.Content)
+		m.chatTextArea.Focus()
+		m.updateChatLines()
+		return m, showStatus("Editing message — enter resends as a new branch, esc cancels")
+	case "left", "h", "right", "l":
+		if m.chatState != ChatStateReady || m.chatStreaming {
+			return m, showStatus("Wait for the response to finish")
+		}
+		delta := 1
+		if msg.String() == "left" || msg.String() == "h" {
+			delta = -1
+		}
+		if !m.switchChatBranch(m.chatCopyIdx, delta) {
+			return m, showStatus("No other branches at this message")
+		}
+		m.chatCopySelected = make(map[int]bool)
+		m.updateChatLines()
+		return m, nil
 	case " ":
 		if m.chatCopyIdx >= 0 && m.chatCopyIdx < len(m.chatMessages) {
 			if m.chatCopySelected == nil {
@@ -597,6 +816,35 @@ func (m model) updateChatCopyMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// regenerateReply re-runs the last user turn. The current reply is kept as a
+// sibling alternative rather than discarded.
+func (m model) regenerateReply() (tea.Model, tea.Cmd) {
+	if (m.chatState != ChatStateReady && m.chatState != ChatStateError) || m.chatStreaming {
+		return m, nil
+	}
+	// The reply (with any tool calls and results) starts after the last user message.
+	last := len(m.chatMessages) - 1
+	for last >= 0 && m.chatMessages[last].Role != "user" {
+		last--
+	}
+	if last < 0 {
+		return m, showStatus("Nothing to regenerate")
+	}
+	if last+1 < len(m.chatMessages) {
+		m.forkAt(last + 1)
+	}
+	m.chatErr = nil
+	m.codeBlocks = nil
+	m.reviewIndex = 0
+	m.reviewScroll = 0
+	m.chatState = ChatStateLoading
+	m.updateChatLines()
+	return m, tea.Batch(
+		m.sendChat(),
+		m.chatSpinner.Tick,
+	)
+}
+
 func (m model) updateResourcePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	// Scan for .md/.txt/.json files in current dir for attachment
 	files := m.scanAttachableFiles()
@@ -638,6 +886,132 @@ func (m model) updateResourcePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// =============================================================================
+// Prompt templates
+// =============================================================================
+
+func (m model) openTemplatePicker() (tea.Model, tea.Cmd) {
+	list, skipped, err := templates.Load(m.config.TemplatesDir)
+	if err != nil {
+		return m, showStatus(fmt.Sprintf("Cannot load templates: %v", err))
+	}
+	var warning tea.Cmd
+	if len(skipped) > 0 {
+		warning = showStatus(fmt.Sprintf("Skipped %d template(s): %s", len(skipped), strings.Join(skipped, "; ")))
+	}
+	if len(list) == 0 {
+		if warning != nil {
+			return m, warning
+		}
+		return m, showStatus(fmt.Sprintf("No templates in %s", m.config.TemplatesDir))
+	}
+	// {{selection}} is the current draft, or the last reply when the draft is empty.
+	selection := strings.TrimSpace(m.chatTextArea.Value())
+	if selection == "" {
+		for i := len(m.chatMessages) - 1; i >= 0; i-- {
+			if m.chatMessages[i].Role == "assistant" {
+				selection = m.chatMessages[i].Content
+				break
+			}
+		}
+	}
+	m.templateList = list
+	m.templateCursor = 0
+	m.templateActive = nil
+	m.templateSelection = selection
+	m.showTemplatePicker = true
+	return m, warning
+}
+
+func (m model) updateTemplatePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Prompting for {{variables}} of the chosen template
+	if m.templateActive != nil {
+		switch msg.String() {
+		case "esc":
+			m.templateActive = nil
+			return m, nil
+		case "enter":
+			name := m.templateVars[len(m.templateValues)]
+			m.templateValues[name] = m.templateInput.Value()
+			if len(m.templateValues) < len(m.templateVars) {
+				m.templateInput.Reset()
+				return m, nil
+			}
+			return m.insertTemplate()
+		}
+		var cmd tea.Cmd
+		m.templateInput, cmd = m.templateInput.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		m.showTemplatePicker = false
+		m.chatTextArea.Focus()
+		return m, nil
+	case "up", "k":
+		if m.templateCursor > 0 {
+			m.templateCursor--
+		}
+	case "down", "j":
+		if m.templateCursor < len(m.templateList)-1 {
+			m.templateCursor++
+		}
+	case "enter":
+		if m.templateCursor >= len(m.templateList) {
+			return m, nil
+		}
+		t := m.templateList[m.templateCursor]
+		m.templateActive = &t
+		m.templateVars = templates.Variables(t.Body)
+		m.templateValues = make(map[string]string)
+		if len(m.templateVars) == 0 {
+			return m.insertTemplate()
+		}
+		m.templateInput = textinput.New()
+		m.templateInput.CharLimit = 2000
+		m.templateInput.Focus()
+	}
+	return m, nil
+}
+
+// insertTemplate renders the active template into the composer and applies
+// any profile/temperature it pins.
+func (m model) insertTemplate() (tea.Model, tea.Cmd) {
+	t := *m.templateActive
+	vars := templates.Builtins(t.Body, m.currentDir, m.templateSelection)
+	for k, v := range m.templateValues {
+		vars[k] = v
+	}
+	m.chatTextArea.SetValue(templates.Render(t.Body, vars))
+	m.chatTextArea.Focus()
+	m.showTemplatePicker = false
+	m.templateActive = nil
+	m.syncChatLayout()
+
+	status := fmt.Sprintf("Inserted template %s", t.Name)
+	if t.Temperature != nil {
+		temp := *t.Temperature
+		m.chatTemperature = &temp
+		status += fmt.Sprintf(" · temp %.2g", temp)
+	}
+	if t.Profile != "" {
+		idx := m.modelConfig.Index(t.Profile)
+		if idx < 0 {
+			return m, showStatus(fmt.Sprintf("%s · profile '%s' not found", status, t.Profile))
+		}
+		if idx != m.profileIndex() {
+			// Only for this chat; the saved default profile stays as it is.
+			m.chatProfile = t.Profile
+			m.chatState = ChatStateCheckingModel
+			m.updateChatLines()
+			return m, tea.Batch(m.checkModel(), m.chatSpinner.Tick, showStatus(status+" · profile "+t.Profile))
+		}
+	}
+	m.updateChatLines()
+	return m, showStatus(status)
+}
+
 // =============================================================================
 // Conversations
 // =============================================================================
@@ -662,7 +1036,7 @@ func (m model) updateConversationList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if err == nil {
 				m.viewMode = ViewChat
 				m.prepareLoadedConversation(loaded)
-				return m, showStatus(fmt.Sprintf("Loaded: %s", conv.Title))
+				return m, tea.Batch(m.fetchContextWindow(), showStatus(fmt.Sprintf("Loaded: %s", conv.Title)))
 			}
 			return m, showStatus(fmt.Sprintf("Failed: %v", err))
 		}
@@ -685,6 +1059,147 @@ func (m model) updateConversationList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m model) updateChangeset(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	blocks := m.actionableBlocks()
+	scrollPreview := func(delta int) {
+		if m.changesetCursor >= len(blocks) {
+			return
+		}
+		lines, _ := m.reviewDiffLines(m.codeBlocks[blocks[m.changesetCursor]])
+		m.changesetScroll = max(0, min(m.changesetScroll+delta, len(lines)-m.changesetPreviewHeight()))
+	}
+	switch msg.String() {
+	case "esc", "q":
+		// Back to reviewing one file at a time.
+		m.viewMode = ViewChat
+		return m, nil
+	case "up", "k":
+		if m.changesetCursor > 0 {
+			m.changesetCursor--
+			m.changesetScroll = 0
+		}
+	case "down", "j":
+		if m.changesetCursor < len(blocks)-1 {
+			m.changesetCursor++
+			m.changesetScroll = 0
+		}
+	case " ", "x":
+		if m.changesetCursor < len(blocks) {
+			i := blocks[m.changesetCursor]
+			if b := m.codeBlocks[i]; b.Denied != nil || b.Err != nil {
+				return m, showStatus("Cannot select " + b.Path)
+			}
+			m.changesetSelected[i] = !m.changesetSelected[i]
+		}
+	case "A":
+		all := true
+		for _, i := range blocks {
+			if b := m.codeBlocks[i]; b.Denied == nil && b.Err == nil && !m.changesetSelected[i] {
+				all = false
+			}
+		}
+		for _, i := range blocks {
+			b := m.codeBlocks[i]
+			m.changesetSelected[i] = !all && b.Denied == nil && b.Err == nil
+		}
+	case "J", "pgdown", "ctrl+d":
+		scrollPreview(m.changesetPreviewHeight() / 2)
+	case "K", "pgup", "ctrl+u":
+		scrollPreview(-m.changesetPreviewHeight() / 2)
+	case "enter", "w":
+		return m.applyChangeset()
+	}
+	return m, nil
+}
+
+func (m model) updateChanges(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var files []journal.File
+	if m.journal != nil {
+		files = m.journal.Files()
+	}
+	switch msg.String() {
+	case "esc", "q":
+		m.viewMode = ViewChat
+		return m, nil
+	case "up", "k":
+		if m.changesCursor > 0 {
+			m.changesCursor--
+		}
+	case "down", "j":
+		if m.changesCursor < len(files)-1 {
+			m.changesCursor++
+		}
+	case "u", "enter":
+		if m.changesCursor < len(files) {
+			return m.revert(revertTarget{path: files[m.changesCursor].Path}, false)
+		}
+	case "U":
+		return m.revert(revertTarget{}, false)
+	}
+	return m, nil
+}
+
+func (m model) updateGit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.gitEditing {
+		switch msg.String() {
+		case "esc":
+			m.gitEditing = false
+			m.gitMessage.Blur()
+			return m, nil
+		case "ctrl+s":
+			m.gitEditing = false
+			m.gitMessage.Blur()
+			return m.gitAction("commit", false)
+		}
+		var cmd tea.Cmd
+		m.gitMessage, cmd = m.gitMessage.Update(msg)
+		return m, cmd
+	}
+	scroll := func(delta int) {
+		m.gitScroll = max(0, min(m.gitScroll+delta, len(diffLines(m.gitDiff))-m.gitDiffHeight()))
+	}
+	switch msg.String() {
+	case "esc", "q":
+		if m.gitCancel != nil {
+			m.gitCancel()
+			m.gitCancel = nil
+			m.gitGenerating = false
+		}
+		m.viewMode = ViewChat
+		return m, nil
+	case "down", "j":
+		scroll(1)
+	case "up", "k":
+		scroll(-1)
+	case "J", "pgdown", "ctrl+d":
+		scroll(m.gitDiffHeight() / 2)
+	case "K", "pgup", "ctrl+u":
+		scroll(-m.gitDiffHeight() / 2)
+	case "r":
+		if err := m.refreshGit(); err != nil {
+			return m, showStatus(fmt.Sprintf("Failed to read git status: %v", err))
+		}
+	case "e":
+		m.gitEditing = true
+		return m, m.gitMessage.Focus()
+	case "g":
+		if m.gitGenerating {
+			return m, nil
+		}
+		if strings.TrimSpace(m.gitDiff) == "" {
+			return m, showStatus("No changes to describe")
+		}
+		m.gitGenerating = true
+		cmd := m.generateCommitMessage()
+		return m, cmd
+	case "s":
+		return m.gitAction("stage", false)
+	case "c":
+		return m.gitAction("commit", false)
+	}
+	return m, nil
+}
+
 func (m model) updateConversationExport(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "esc":
@@ -709,12 +1224,17 @@ func (m model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.settingsInputs = nil
 		return m, nil
 	case "enter":
-		if len(m.settingsInputs) >= 3 {
+		if len(m.settingsInputs) >= 4 {
+			strategy := budget.NormalizeStrategy(m.settingsInputs[3].Value())
+			if strategy == "" {
+				return m, showStatus("Context strategy must be summarize, truncate or off")
+			}
 			m.settings.MainPrompt = m.settingsInputs[0].Value()
 			m.settings.UserName = m.settingsInputs[1].Value()
 			if t, err := strconv.Atoi(m.settingsInputs[2].Value()); err == nil && t > 0 {
 				m.settings.ChatTimeout = t
 			}
+			m.settings.ContextStrategy = strategy
 			storage.SaveSettings(m.settings)
 			m.viewMode = ViewMenu
 			m.settingsInputs = nil
@@ -731,7 +1251,7 @@ func (m model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m *model) initSettingsInputs() {
-	m.settingsInputs = make([]textinput.Model, 3)
+	m.settingsInputs = make([]textinput.Model, 4)
 
 	m.settingsInputs[0] = textinput.New()
 	m.settingsInputs[0].SetValue(m.settings.MainPrompt)
@@ -745,6 +1265,11 @@ func (m *model) initSettingsInputs() {
 	m.settingsInputs[2] = textinput.New()
 	m.settingsInputs[2].SetValue(fmt.Sprintf("%d", m.settings.ChatTimeout))
 	m.settingsInputs[2].CharLimit = 10
+
+	m.settingsInputs[3] = textinput.New()
+	m.settingsInputs[3].SetValue(budget.NormalizeStrategy(m.settings.ContextStrategy))
+	m.settingsInputs[3].Placeholder = "summarize, truncate or off"
+	m.settingsInputs[3].CharLimit = 10
 }
 
 // =============================================================================
@@ -758,10 +1283,12 @@ func (m model) updateModelManager(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "up", "k":
 		if m.modelSelection > 0 {
 			m.modelSelection--
+			m.profileModels = nil
 		}
 	case "down", "j":
 		if m.modelSelection < len(m.modelConfig.Profiles)-1 {
 			m.modelSelection++
+			m.profileModels = nil
 		}
 	case "enter":
 		m.modelConfig.CurrentProfile = m.modelSelection
@@ -772,22 +1299,26 @@ func (m model) updateModelManager(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.libraryModels = ollama.PopularModels()
 		m.librarySelection = 0
 		m.libraryFilter = ""
-		return m, refreshInstalledModels()
+		return m, refreshInstalledModels(m.libraryEndpoint())
 	case "n":
 		m.viewMode = ViewModelCreate
 		m.editingProfile = -1
-		m.modelInputs = m.newProfileInputs("", "ollama", "", "", "0.7")
+		m.modelInputs = m.newProfileInputs(storage.ModelProfile{Provider: "ollama", Temperature: 0.7})
 	case "e":
 		if m.modelSelection < len(m.modelConfig.Profiles) {
 			p := m.modelConfig.Profiles[m.modelSelection]
 			m.viewMode = ViewModelCreate
 			m.editingProfile = m.modelSelection
-			m.modelInputs = m.newProfileInputs(p.Name, p.Provider, p.Model, p.SystemPrompt, fmt.Sprintf("%.1f", p.Temperature))
+			m.modelInputs = m.newProfileInputs(p)
+		}
+	case "l":
+		if m.modelSelection < len(m.modelConfig.Profiles) {
+			return m, listProfileModels(m.modelConfig.Profiles[m.modelSelection])
 		}
 	case "p":
 		if m.modelSelection < len(m.modelConfig.Profiles) {
 			profile := m.modelConfig.Profiles[m.modelSelection]
-			if storage.NormalizeProvider(profile.Provider) != "ollama" {
+			if backend, err := provider.ForProfile(profile); err != nil || !backend.Capabilities().PullModels {
 				return m, showStatus("Pull is only available for Ollama profiles")
 			}
 			name := profile.Model
@@ -795,7 +1326,7 @@ func (m model) updateModelManager(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.modelPullName = name
 			m.modelPullStatus = fmt.Sprintf("Pulling: %s...", name)
 			m.modelPullError = nil
-			return m, pullOllamaModel(name)
+			return m, pullOllamaModel(m.libraryEndpoint(), name)
 		}
 	case "d":
 		if len(m.modelConfig.Profiles) > 1 && m.modelSelection < len(m.modelConfig.Profiles) {
@@ -821,20 +1352,26 @@ func (m model) updateModelCreate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.editingProfile = -1
 		return m, nil
 	case "enter":
-		if len(m.modelInputs) >= 5 && m.modelInputs[0].Value() != "" && m.modelInputs[2].Value() != "" {
+		if len(m.modelInputs) >= 9 && m.modelInputs[0].Value() != "" && m.modelInputs[2].Value() != "" {
 			temp := 0.7
 			if t := m.modelInputs[4].Value(); t != "" {
 				if parsed, err := strconv.ParseFloat(t, 64); err == nil && parsed >= 0 && parsed <= 1 {
 					temp = parsed
 				}
 			}
-			provider := storage.NormalizeProvider(m.modelInputs[1].Value())
-			if provider != "ollama" && provider != "gemini" {
-				return m, showStatus("Provider must be ollama or gemini")
+			providerName := storage.NormalizeProvider(m.modelInputs[1].Value())
+			if _, err := provider.New(providerName, provider.Endpoint{}); err != nil {
+				return m, showStatus("Provider must be one of: " + strings.Join(provider.Names(), ", "))
 			}
 			profile := storage.ModelProfile{
-				Name: m.modelInputs[0].Value(), Provider: provider, Model: m.modelInputs[2].Value(),
+				Name: m.modelInputs[0].Value(), Provider: providerName, Model: m.modelInputs[2].Value(),
 				SystemPrompt: m.modelInputs[3].Value(), Temperature: temp,
+				BaseURL:   strings.TrimSpace(m.modelInputs[5].Value()),
+				APIKeyEnv: strings.TrimSpace(m.modelInputs[6].Value()),
+				Headers:   storage.ParseHeaders(m.modelInputs[7].Value()),
+			}
+			if err := profile.SetOptions(m.modelInputs[8].Value()); err != nil {
+				return m, showStatus(fmt.Sprintf("Cannot save options: %v", err))
 			}
 			if m.editingProfile >= 0 && m.editingProfile < len(m.modelConfig.Profiles) {
 				m.modelConfig.Profiles[m.editingProfile] = profile
@@ -888,19 +1425,19 @@ func (m model) updateModelLibrary(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.librarySelection = 0
 		}
 	case "r":
-		return m, refreshInstalledModels()
+		return m, refreshInstalledModels(m.libraryEndpoint())
 	case "enter":
 		filtered := m.getFilteredLibrary()
 		if m.librarySelection < len(filtered) {
 			name := filtered[m.librarySelection].Name
-			if ollama.IsModelInstalled(name, m.installedModels) {
+			if provider.HasModel(name, m.installedModels) {
 				return m, showStatus(fmt.Sprintf("%s already installed", name))
 			}
 			m.viewMode = ViewModelPull
 			m.modelPullName = name
 			m.modelPullStatus = fmt.Sprintf("Installing %s...", name)
 			m.modelPullError = nil
-			return m, pullOllamaModel(name)
+			return m, pullOllamaModel(m.libraryEndpoint(), name)
 		}
 	default:
 		key := msg.String()
@@ -921,8 +1458,36 @@ func (m model) updateConfirmDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		switch m.confirmDialog.Action {
 		case ConfirmDeleteModel:
 			return m.executeDeleteModel()
+		case ConfirmRevert:
+			m.viewMode = m.confirmDialog.PreviousView
+			m.confirmDialog = nil
+			return m.revert(m.pendingRevert, true)
+		case ConfirmGitUnrelated:
+			m.viewMode = m.confirmDialog.PreviousView
+			m.confirmDialog = nil
+			return m.gitAction(m.pendingGitAction, true)
+		case ConfirmToolCall:
+			m.confirmDialog = nil
+			m.viewMode = ViewChat
+			if len(m.pendingToolCalls) == 0 {
+				return m, nil
+			}
+			return m, tea.Batch(m.runToolCall(m.pendingToolCalls[0]), m.chatSpinner.Tick)
+		}
+	case "a", "A":
+		if command, ok := m.pendingCommand(); ok && m.confirmDialog.Action == ConfirmToolCall {
+			m.allowCommand(command)
+			m.confirmDialog = nil
+			m.viewMode = ViewChat
+			return m, tea.Batch(m.runToolCall(m.pendingToolCalls[0]), m.chatSpinner.Tick,
+				showStatus("Always allowed here: "+command))
 		}
 	case "n", "N", "esc":
+		if m.confirmDialog.Action == ConfirmToolCall {
+			m.confirmDialog = nil
+			m.viewMode = ViewChat
+			return m.finishToolCall("The user declined this tool call.")
+		}
 		prev := m.confirmDialog.PreviousView
 		m.confirmDialog = nil
 		m.viewMode = prev
@@ -983,26 +1548,41 @@ func (m model) updateFocusedInput(inputs []textinput.Model, msg tea.KeyMsg) (tea
 	return m, nil
 }
 
-func (m *model) newProfileInputs(name, provider, modelName, prompt, temp string) []textinput.Model {
-	inputs := make([]textinput.Model, 5)
+func (m *model) newProfileInputs(p storage.ModelProfile) []textinput.Model {
+	inputs := make([]textinput.Model, 9)
 	inputs[0] = textinput.New()
-	inputs[0].SetValue(name)
+	inputs[0].SetValue(p.Name)
 	inputs[0].Placeholder = "My Assistant"
 	inputs[0].Focus()
 	inputs[1] = textinput.New()
-	inputs[1].SetValue(storage.NormalizeProvider(provider))
-	inputs[1].Placeholder = "ollama or gemini"
+	inputs[1].SetValue(storage.NormalizeProvider(p.Provider))
+	inputs[1].Placeholder = strings.Join(provider.Names(), ", ")
 	inputs[2] = textinput.New()
-	inputs[2].SetValue(modelName)
+	inputs[2].SetValue(p.Model)
 	inputs[2].Placeholder = "llama3.2:3b or gemini-2.5-flash"
 	inputs[3] = textinput.New()
-	inputs[3].SetValue(prompt)
+	inputs[3].SetValue(p.SystemPrompt)
 	inputs[3].Placeholder = "You are a helpful assistant..."
 	inputs[3].CharLimit = 500
 	inputs[4] = textinput.New()
-	inputs[4].SetValue(temp)
+	inputs[4].SetValue(fmt.Sprintf("%.1f", p.Temperature))
 	inputs[4].Placeholder = "0.7"
 	inputs[4].CharLimit = 3
+	inputs[5] = textinput.New()
+	inputs[5].SetValue(p.BaseURL)
+	inputs[5].Placeholder = "blank = provider default / env var (OLLAMA_HOST, OPENAI_BASE_URL, ...)"
+	inputs[6] = textinput.New()
+	inputs[6].SetValue(p.APIKeyEnv)
+	inputs[6].Placeholder = "blank = provider default (GEMINI_API_KEY, OPENAI_API_KEY, ...)"
+	inputs[6].CharLimit = 100
+	inputs[7] = textinput.New()
+	inputs[7].SetValue(storage.FormatHeaders(p.Headers))
+	inputs[7].Placeholder = "X-Org: team; Authorization: Bearer $PROXY_TOKEN"
+	inputs[7].CharLimit = 500
+	inputs[8] = textinput.New()
+	inputs[8].SetValue(p.OptionsString())
+	inputs[8].Placeholder = "num_ctx=8192 top_p=0.9 top_k=40 stop=</s>|### keep_alive=10m"
+	inputs[8].CharLimit = 300
 	return inputs
 }
 
@@ -1022,48 +1602,69 @@ func (m *model) checkModel() tea.Cmd {
 	return func() tea.Msg {
 		profile := m.currentProfile()
 		name := profile.Model
-		provider := storage.NormalizeProvider(profile.Provider)
-		switch provider {
-		case "ollama":
-			avail, err := ollama.CheckModel(name)
-			if err != nil {
-				return CheckModelMsg{Available: false, ModelName: name, Provider: provider, Err: err}
-			}
-			return CheckModelMsg{Available: avail, ModelName: name, Provider: provider, CanInstall: true}
-		case "gemini":
-			if err := gemini.CheckModel(name); err != nil {
-				return CheckModelMsg{Available: false, ModelName: name, Provider: provider, Reason: err.Error()}
-			}
-			return CheckModelMsg{Available: true, ModelName: name, Provider: provider}
-		default:
-			return CheckModelMsg{
-				Available: false,
-				ModelName: name,
-				Provider:  provider,
-				Reason:    fmt.Sprintf("Unsupported provider: %s", provider),
-			}
+		providerName := storage.NormalizeProvider(profile.Provider)
+		backend, err := provider.ForProfile(profile)
+		if err != nil {
+			return CheckModelMsg{Available: false, ModelName: name, Provider: providerName, Reason: err.Error()}
+		}
+		avail, err := backend.CheckModel(context.Background(), name)
+		if err != nil {
+			return CheckModelMsg{Available: false, ModelName: name, Provider: providerName, Err: err}
+		}
+		return CheckModelMsg{
+			Available:  avail,
+			ModelName:  name,
+			Provider:   providerName,
+			CanInstall: backend.Capabilities().PullModels,
+			Reason:     fmt.Sprintf("Model '%s' is not served by the %s endpoint", name, providerName),
+		}
+	}
+}
+
+// fetchContextWindow asks the provider for the current model's context size,
+// which drives the header usage bar.
+func (m *model) fetchContextWindow() tea.Cmd {
+	profile := m.currentProfile()
+	return func() tea.Msg {
+		backend, err := provider.ForProfile(profile)
+		if err != nil {
+			return contextWindowMsg{model: profile.Model}
 		}
+		size := backend.ContextWindow(context.Background(), profile.Model, provider.OptionsFor(profile))
+		return contextWindowMsg{model: profile.Model, size: size}
 	}
 }
 
 func (m *model) pullModel() tea.Cmd {
 	return func() tea.Msg {
-		name := m.currentProfile().Model
-		if err := ollama.PullModel(name); err != nil {
-			return CheckModelMsg{Available: false, ModelName: name, Provider: "ollama", Err: err}
+		profile := m.currentProfile()
+		name := profile.Model
+		providerName := storage.NormalizeProvider(profile.Provider)
+		backend, err := provider.ForProfile(profile)
+		if err == nil {
+			err = backend.PullModel(context.Background(), name)
 		}
-		return CheckModelMsg{Available: true, ModelName: name, Provider: "ollama"}
+		if err != nil {
+			return CheckModelMsg{Available: false, ModelName: name, Provider: providerName, Err: err}
+		}
+		return CheckModelMsg{Available: true, ModelName: name, Provider: providerName}
 	}
 }
 
-func (m *model) sendChat(userMsg string) tea.Cmd {
+// sendChat asks the model to answer the current turn: the last user message
+// plus any tool calls and results that followed it.
+func (m *model) sendChat() tea.Cmd {
 	ctx, cancel := context.WithCancel(context.Background())
 	m.cancelChat = cancel
 
 	profile := m.currentProfile()
 	systemPrompt := profile.SystemPrompt
-	if m.settings.MainPrompt != "" {
-		systemPrompt = m.settings.MainPrompt + "\n\n" + systemPrompt
+	if m.chatSystemPrompt != "" {
+		systemPrompt = m.chatSystemPrompt
+	}
+	systemPrompt = composeSystemPrompt(m.settings.MainPrompt, systemPrompt)
+	if m.settings.EditBlocks {
+		systemPrompt += "\n\n" + editFormatPrompt
 	}
 
 	// Attach RAG resources to system prompt
@@ -1080,103 +1681,152 @@ func (m *model) sendChat(userMsg string) tea.Cmd {
 	}
 	systemPrompt = strings.TrimSpace(systemPrompt)
 	baseDir := m.currentDir
+	temperature := profile.Temperature
+	if m.chatTemperature != nil {
+		temperature = *m.chatTemperature
+	}
 
-	if storage.NormalizeProvider(profile.Provider) == "gemini" {
-		var msgs []gemini.ChatMessage
-		for _, msg := range m.chatMessages[:len(m.chatMessages)-1] {
-			if msg.Role == "user" || msg.Role == "assistant" {
-				content := msg.Content
-				if msg.Role == "user" {
-					content = resolveAtReferences(content, baseDir)
-				}
-				msgs = append(msgs, gemini.ChatMessage{Role: msg.Role, Content: content})
-			}
-		}
-		msgs = append(msgs, gemini.ChatMessage{Role: "user", Content: resolveAtReferences(userMsg, baseDir)})
-
-		req := gemini.ChatRequest{
-			Model:       profile.Model,
-			Messages:    msgs,
-			System:      systemPrompt,
-			Temperature: profile.Temperature,
-			Timeout:     time.Duration(m.settings.ChatTimeout) * time.Second,
+	turn := len(m.chatMessages) - 1
+	for turn > 0 && m.chatMessages[turn].Role != "user" {
+		turn--
+	}
+	if turn == len(m.chatMessages)-1 {
+		m.toolRounds = 0
+	}
+	var current []provider.Message
+	for _, msg := range m.chatMessages[turn:] {
+		if msg.Role == "hook" {
+			continue
 		}
+		current = append(current, toProviderMessage(msg, baseDir))
+	}
 
-		return func() tea.Msg {
-			ch, err := gemini.ChatStream(ctx, req)
-			if err != nil {
-				if ctx.Err() != nil {
-					return InterruptMsg{}
-				}
-				return ResponseMsg{Err: err}
-			}
-			return streamStartedMsg{ch: adaptGeminiStream(ch)}
+	// History before the summary cutoff is represented by chatSummary.
+	prior := m.chatMessages[:turn]
+	start := m.chatSummaryThrough
+	if start > len(prior) {
+		start = len(prior)
+	}
+	var history []provider.Message
+	var historyIdx []int // chatMessages index of each history entry
+	for i := start; i < len(prior); i++ {
+		switch prior[i].Role {
+		case "user", "assistant", "tool":
+			history = append(history, toProviderMessage(prior[i], baseDir))
+			historyIdx = append(historyIdx, i)
 		}
 	}
 
-	var msgs []ollama.ChatMessage
-	if systemPrompt != "" {
-		msgs = append(msgs, ollama.ChatMessage{Role: "system", Content: systemPrompt})
+	// Fit history into the context budget; whatever overflows is summarized or dropped.
+	strategy := budget.NormalizeStrategy(m.settings.ContextStrategy)
+	limit := 0
+	if strategy != budget.StrategyOff {
+		limit = budget.Limit(m.chatContextSize)
 	}
-	for _, msg := range m.chatMessages[:len(m.chatMessages)-1] {
-		if msg.Role == "user" || msg.Role == "assistant" {
-			content := msg.Content
-			if msg.Role == "user" {
-				content = resolveAtReferences(content, baseDir)
-			}
-			msgs = append(msgs, ollama.ChatMessage{Role: msg.Role, Content: content})
-		}
+	summary := m.chatSummary
+	fixed := budget.EstimateTokens(budget.WithSummary(systemPrompt, summary))
+	for _, msg := range current {
+		fixed += budget.MessageTokens(msg)
+	}
+	cut := budget.Fit(history, fixed, limit)
+	// A tool result cannot lead the history without the call it answers.
+	for cut < len(history) && history[cut].Role == "tool" {
+		cut++
+	}
+	overflow := flattenToolMessages(history[:cut])
+	summarizedThrough := len(prior)
+	if cut < len(historyIdx) {
+		summarizedThrough = historyIdx[cut]
+	}
+	if len(overflow) > 0 && strategy == budget.StrategyTruncate {
+		m.chatContextNote = fmt.Sprintf("dropped %d earlier msgs", summarizedThrough)
 	}
-	msgs = append(msgs, ollama.ChatMessage{Role: "user", Content: resolveAtReferences(userMsg, baseDir)})
 
-	req := ollama.ChatRequest{
-		Model: profile.Model, Messages: msgs,
-		Temperature: profile.Temperature,
+	req := provider.ChatRequest{
+		Model:       profile.Model,
+		Messages:    append(history[cut:len(history):len(history)], current...),
+		System:      budget.WithSummary(systemPrompt, summary),
+		Temperature: temperature,
+		Options:     provider.OptionsFor(profile),
 		Timeout:     time.Duration(m.settings.ChatTimeout) * time.Second,
 	}
 
+	useTools := m.settings.Tools
+
 	return func() tea.Msg {
-		ch, err := ollama.ChatStream(ctx, req)
+		backend, err := provider.ForProfile(profile)
+		if err != nil {
+			return ResponseMsg{Err: err}
+		}
+		if useTools && backend.Capabilities().Tools {
+			req.Tools = providerTools()
+			systemPrompt = strings.TrimSpace(systemPrompt + "\n\n" + toolSystemNote(baseDir))
+			req.System = budget.WithSummary(systemPrompt, summary)
+		} else {
+			req.Messages = flattenToolMessages(req.Messages)
+		}
+
+		started := streamStartedMsg{}
+		if len(overflow) > 0 && strategy == budget.StrategySummarize {
+			newSummary, err := budget.Summarize(ctx, backend, req, summary, overflow, limit)
+			switch {
+			case err == nil:
+				req.System = budget.WithSummary(systemPrompt, newSummary)
+				started.summary = newSummary
+				started.summarizedThrough = summarizedThrough
+				started.contextNote = fmt.Sprintf("summarized %d earlier msgs", summarizedThrough)
+			case ctx.Err() != nil:
+				return InterruptMsg{}
+			default:
+				// The request is already trimmed, so fall back to dropping the overflow.
+				started.contextNote = fmt.Sprintf("summary failed, dropped %d msgs", summarizedThrough)
+			}
+		}
+
+		ch, err := backend.ChatStream(ctx, req)
 		if err != nil {
 			if ctx.Err() != nil {
 				return InterruptMsg{}
 			}
 			return ResponseMsg{Err: err}
 		}
-		return streamStartedMsg{ch: ch}
+		started.ch = ch
+		return started
 	}
 }
 
-func adaptGeminiStream(src <-chan gemini.StreamChunk) <-chan ollama.StreamChunk {
-	dst := make(chan ollama.StreamChunk)
-	go func() {
-		defer close(dst)
-		for chunk := range src {
-			dst <- ollama.StreamChunk{
-				Content:      chunk.Content,
-				Done:         chunk.Done,
-				Duration:     chunk.Duration,
-				PromptTokens: chunk.PromptTokens,
-				TotalTokens:  chunk.TotalTokens,
-				Err:          chunk.Err,
-			}
+// libraryEndpoint is the Ollama server the library and pull actions target:
+// the selected profile's when it is an Ollama profile, else the default host.
+func (m *model) libraryEndpoint() provider.Endpoint {
+	if m.modelSelection >= 0 && m.modelSelection < len(m.modelConfig.Profiles) {
+		p := m.modelConfig.Profiles[m.modelSelection]
+		if storage.NormalizeProvider(p.Provider) == "ollama" {
+			return provider.EndpointFor(p)
 		}
-	}()
-	return dst
+	}
+	return provider.Endpoint{}
 }
 
-func pullOllamaModel(name string) tea.Cmd {
+func pullOllamaModel(ep provider.Endpoint, name string) tea.Cmd {
 	return func() tea.Msg {
-		if err := ollama.PullModel(name); err != nil {
+		backend, err := provider.New("ollama", ep)
+		if err == nil {
+			err = backend.PullModel(context.Background(), name)
+		}
+		if err != nil {
 			return ModelPullMsg{Err: err}
 		}
 		return ModelPullMsg{Success: true}
 	}
 }
 
-func refreshInstalledModels() tea.Cmd {
+func refreshInstalledModels(ep provider.Endpoint) tea.Cmd {
 	return func() tea.Msg {
-		models, err := ollama.ListModels()
+		backend, err := provider.New("ollama", ep)
+		if err != nil {
+			return statusMsg{message: fmt.Sprintf("Failed to refresh: %v", err)}
+		}
+		models, err := backend.ListModels(context.Background())
 		if err != nil {
 			return statusMsg{message: fmt.Sprintf("Failed to refresh: %v", err)}
 		}
@@ -1184,7 +1834,25 @@ func refreshInstalledModels() tea.Cmd {
 	}
 }
 
-func listenForChunk(ch <-chan ollama.StreamChunk) tea.Cmd {
+// listProfileModels asks the profile's endpoint which models it serves.
+func listProfileModels(profile storage.ModelProfile) tea.Cmd {
+	return func() tea.Msg {
+		backend, err := provider.ForProfile(profile)
+		if err != nil {
+			return statusMsg{message: fmt.Sprintf("Failed to list models: %v", err)}
+		}
+		if !backend.Capabilities().ListModels {
+			return statusMsg{message: fmt.Sprintf("Cannot list models for %s profiles", backend.Name())}
+		}
+		models, err := backend.ListModels(context.Background())
+		if err != nil {
+			return statusMsg{message: fmt.Sprintf("Failed to list models: %v", err)}
+		}
+		return profileModelsMsg{profile: profile.Name, models: models}
+	}
+}
+
+func listenForChunk(ch <-chan provider.StreamChunk) tea.Cmd {
 	return func() tea.Msg {
 		chunk, ok := <-ch
 		if !ok {
@@ -1197,6 +1865,7 @@ func listenForChunk(ch <-chan ollama.StreamChunk) tea.Cmd {
 			Duration:     chunk.Duration,
 			PromptTokens: chunk.PromptTokens,
 			TotalTokens:  chunk.TotalTokens,
+			ToolCalls:    chunk.ToolCalls,
 		}
 	}
 }
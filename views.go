@@ -2,12 +2,14 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"dwight/internal/ollama"
+	"dwight/internal/diff"
+	"dwight/internal/journal"
+	"dwight/internal/policy"
+	"dwight/internal/provider"
 	"dwight/internal/storage"
 	s "dwight/internal/styles"
 
@@ -25,6 +27,8 @@ func (m model) View() string {
 	case ViewChat:
 		if m.showResourcePicker {
 			content = m.viewResourcePicker()
+		} else if m.showTemplatePicker {
+			content = m.viewTemplatePicker()
 		} else {
 			content = m.viewChat()
 		}
@@ -48,6 +52,12 @@ func (m model) View() string {
 		content = m.viewModelLibrary()
 	case ViewConfirmDialog:
 		content = m.viewConfirmDialog()
+	case ViewChanges:
+		content = m.viewChanges()
+	case ViewChangeset:
+		content = m.viewChangeset()
+	case ViewGit:
+		content = m.viewGit()
 	default:
 		return ""
 	}
@@ -131,12 +141,12 @@ func (m model) viewMenu() string {
 
 func (m model) viewChat() string {
 	profile := m.currentProfile()
-	provider := storage.NormalizeProvider(profile.Provider)
+	providerName := storage.NormalizeProvider(profile.Provider)
 
 	// Header: model name + context bar + stats
 	header := s.Title.Render("dwight") +
 		s.Dim.Render(" | profile: ") + s.Title.Render(profile.Name) +
-		s.Dim.Render(" | provider: ") + s.Success.Render(provider) +
+		s.Dim.Render(" | provider: ") + s.Success.Render(providerName) +
 		s.Dim.Render(" | model: ") + s.Success.Render(profile.Model)
 
 	// Use the last message's TotalTokens — Ollama reports cumulative session tokens per call.
@@ -144,10 +154,7 @@ func (m model) viewChat() string {
 	if len(m.chatMessages) > 0 {
 		totalTokens = m.chatMessages[len(m.chatMessages)-1].TotalTokens
 	}
-	ctxSize := 0
-	if provider == "ollama" {
-		ctxSize = ollama.ContextWindowSize(profile.Model)
-	}
+	ctxSize := m.chatContextSize
 
 	if totalTokens > 0 && ctxSize > 0 {
 		// Context usage bar
@@ -191,6 +198,20 @@ func (m model) viewChat() string {
 		header += s.Dim.Render(fmt.Sprintf(" | %d files", total))
 	}
 
+	if m.chatTemperature != nil {
+		header += s.Dim.Render(fmt.Sprintf(" | temp %.2g", *m.chatTemperature))
+	}
+	if m.chatSystemPrompt != "" {
+		header += s.Dim.Render(" | custom system")
+	}
+	if m.settings.Tools {
+		header += s.Dim.Render(" | tools")
+	}
+
+	if m.chatContextNote != "" {
+		header += s.Warning.Render(" | " + m.chatContextNote)
+	}
+
 	// Scroll indicator
 	if len(m.chatLines) > m.chatMaxLines {
 		maxScroll := len(m.chatLines) - m.chatMaxLines
@@ -231,13 +252,21 @@ func (m model) viewChat() string {
 	var footer string
 	switch {
 	case m.chatCopyMode:
-		footer = s.Footer("j/k", "navigate", "space", "mark", "y/enter", "copy", "esc", "cancel")
+		footer = s.Footer("j/k", "navigate", "space", "mark", "y/enter", "copy", "e", "edit", "h/l", "branch", "esc", "cancel")
 	case m.chatState == ChatStateReview:
-		footer = s.Footer("a", "accept", "r", "refine", "n", "skip")
+		footer = s.Footer("a", "accept", "r", "refine", "n", "skip", "j/k", "scroll diff", "pgup/dn", "page")
+		if len(m.actionableBlocks()) > 1 {
+			footer += s.Separator.Render(" | ") + s.Footer("c", "all files")
+		}
 	case m.chatState == ChatStateLoading || m.chatStreaming:
 		footer = s.Footer("esc", "interrupt", "ctrl+c", "interrupt")
 	default:
-		footer = s.Footer("enter", "send", "alt+enter", "newline", "up/down", "move cursor", "pgup/dn", "scroll chat", "ctrl+y", "copy msg", "ctrl+n", "new")
+		footer = s.Footer("enter", "send", "alt+enter", "newline", "up/down", "move cursor", "pgup/dn", "scroll chat", "ctrl+y", "copy msg", "alt+r", "regenerate", "ctrl+n", "new")
+		if m.hooksRunning {
+			footer = s.Dim.Render("running hooks... ") + s.Footer("esc", "stop hooks") + s.Separator.Render(" | ") + footer
+		} else if m.hookFailures != "" {
+			footer = s.Footer("alt+f", "send hook failures to the model") + s.Separator.Render(" | ") + footer
+		}
 	}
 	status := m.renderStatus()
 
@@ -261,6 +290,9 @@ func (m model) viewChatComposer() string {
 		s.Dim.Render("up/down moves inside the draft"),
 		s.Dim.Render("pgup/pgdn scrolls chat history"),
 	}
+	if m.chatEditID != "" {
+		meta = append([]string{s.Warning.Render("editing earlier message · esc cancels")}, meta...)
+	}
 	if hidden := lineCount - m.chatTextArea.Height(); hidden > 0 {
 		meta = append(meta, s.Warning.Render(fmt.Sprintf("%d more line(s) above", hidden)))
 	}
@@ -324,6 +356,216 @@ func (m model) viewConversationList() string {
 	return lipgloss.JoinVertical(lipgloss.Left, parts...)
 }
 
+func (m model) viewChangeset() string {
+	blocks := m.actionableBlocks()
+	selected, totalAdded, totalRemoved := 0, 0, 0
+
+	var list strings.Builder
+	for n, i := range blocks {
+		b := m.codeBlocks[i]
+		box, state, counts := "[ ]", "", ""
+		if m.changesetSelected[i] {
+			box = "[x]"
+		}
+		switch {
+		case b.Denied != nil:
+			box, state, counts = "[-]", "blocked", truncateStr(b.Denied.Error(), 40)
+		case b.Err != nil:
+			box, state, counts = "[-]", "failed", truncateStr(b.Err.Error(), 40)
+		default:
+			created, added, removed := blockChange(b)
+			state = "modified"
+			if created {
+				state = "new"
+			}
+			counts = fmt.Sprintf("+%d -%d", added, removed)
+			if m.changesetSelected[i] {
+				selected++
+				totalAdded += added
+				totalRemoved += removed
+			}
+		}
+		line := fmt.Sprintf("%s %-8s %-40s %s", box, state, truncateStr(b.Path, 40), counts)
+		switch {
+		case n == m.changesetCursor:
+			list.WriteString(s.Selected.Render("> " + line))
+		case b.Denied != nil || b.Err != nil:
+			list.WriteString(s.Error.Render("  " + line))
+		default:
+			list.WriteString(s.Normal.Render("  " + line))
+		}
+		if n < len(blocks)-1 {
+			list.WriteString("\n")
+		}
+	}
+
+	title := s.Title.Render("Changeset") +
+		s.Dim.Render(fmt.Sprintf(" · %d file(s) · %d selected · +%d -%d", len(blocks), selected, totalAdded, totalRemoved))
+
+	// Diff preview of the file under the cursor
+	var preview strings.Builder
+	if m.changesetCursor < len(blocks) {
+		b := m.codeBlocks[blocks[m.changesetCursor]]
+		lines, summary := m.reviewDiffLines(b)
+		height := m.changesetPreviewHeight()
+		preview.WriteString(s.Title.Render(b.Path) + s.Dim.Render("  "+summary))
+		if len(lines) > height {
+			scroll := min(m.changesetScroll, len(lines)-height)
+			preview.WriteString(s.Dim.Render(fmt.Sprintf("  [%d-%d of %d]", scroll+1, scroll+height, len(lines))))
+			lines = lines[scroll : scroll+height]
+		}
+		for _, line := range append(targetWarnings(b.Target), lines...) {
+			preview.WriteString("\n" + line)
+		}
+	}
+
+	footer := s.Footer("j/k", "navigate", "space", "toggle", "A", "toggle all", "J/K", "scroll diff", "enter", "apply selected", "esc", "one at a time")
+	parts := []string{title, "", list.String(), "", preview.String()}
+	if status := m.renderStatus(); status != "" {
+		parts = append(parts, "", status)
+	}
+	parts = append(parts, "", footer)
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
+}
+
+func (m model) viewChanges() string {
+	title := s.Title.Render("Changes This Session")
+	status := m.renderStatus()
+
+	var content strings.Builder
+	var files []journal.File
+	if m.journal != nil {
+		files = m.journal.Files()
+	}
+	if len(files) == 0 {
+		content.WriteString(s.Dim.Render("No files written yet. Accepted code blocks and tool writes show up here."))
+	} else {
+		content.WriteString(s.Dim.Render(fmt.Sprintf("%d file(s) · %s\n\n", len(files), filepath.Join(journal.Dir(), m.journal.ID+".json"))))
+		content.WriteString("\n")
+		for i, f := range files {
+			state := "modified"
+			switch {
+			case f.Reverted:
+				state = "reverted"
+			case f.Created:
+				state = "new"
+			}
+			added, removed := diff.Stats(diff.Compute(f.Original, f.Content, 0))
+			line := fmt.Sprintf("%-9s %-40s %2d write(s) %10s | %s",
+				state,
+				truncateStr(m.relPath(f.Path), 40),
+				f.Writes,
+				fmt.Sprintf("+%d -%d", added, removed),
+				formatTimeAgo(f.Last))
+			switch {
+			case i == m.changesCursor:
+				content.WriteString(s.Selected.Render("> " + line))
+			case f.Reverted:
+				content.WriteString(s.Dim.Render("  " + line))
+			default:
+				content.WriteString(s.Normal.Render("  " + line))
+			}
+			content.WriteString("\n")
+		}
+	}
+
+	footer := s.Footer("j/k", "navigate", "u/enter", "revert file", "U", "revert all", "esc", "back")
+	parts := []string{title, "", content.String()}
+	if status != "" {
+		parts = append(parts, "", status)
+	}
+	parts = append(parts, "", footer)
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
+}
+
+func (m model) viewGit() string {
+	root := m.workContext.GitRoot
+	header := s.Dim.Render(" · " + root)
+	if m.gitBranch != "" {
+		header = s.Dim.Render(" · "+m.gitBranch) + header
+	}
+	title := s.Title.Render("Git") + header
+	width := m.safeWidth() - 4
+
+	var content strings.Builder
+	switch {
+	case len(m.gitFiles) == 0:
+		content.WriteString(s.Dim.Render("No files written this session. Accepted code blocks and tool writes show up here."))
+	case strings.TrimSpace(m.gitStatus) == "":
+		content.WriteString(s.Dim.Render(fmt.Sprintf("The %d file(s) written this session have no uncommitted changes.", len(m.gitFiles))))
+	default:
+		content.WriteString(s.Dim.Render("Files written this session:"))
+		for _, line := range diffLines(m.gitStatus) {
+			content.WriteString("\n" + s.Normal.Render("  "+truncateStr(line, width)))
+		}
+	}
+	if len(m.gitUnrelated) > 0 {
+		content.WriteString("\n\n" + s.Warning.Render(truncateStr(fmt.Sprintf("⚠ %d other staged file(s) would be committed too: %s",
+			len(m.gitUnrelated), strings.Join(m.gitUnrelated, ", ")), width)))
+	}
+
+	// Diff of the session's files against HEAD
+	lines := diffLines(m.gitDiff)
+	height := m.gitDiffHeight()
+	diffTitle := s.Title.Render("Diff")
+	if len(lines) > height {
+		scroll := min(m.gitScroll, len(lines)-height)
+		diffTitle += s.Dim.Render(fmt.Sprintf("  [%d-%d of %d]", scroll+1, scroll+height, len(lines)))
+		lines = lines[scroll : scroll+height]
+	}
+	var diffView strings.Builder
+	diffView.WriteString(diffTitle)
+	if len(lines) == 0 {
+		diffView.WriteString("\n" + s.Dim.Render("  no changes"))
+	}
+	for _, line := range lines {
+		text := truncateStr(line, width)
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"),
+			strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "), strings.HasPrefix(line, "new file"):
+			text = s.Title.Render(text)
+		case strings.HasPrefix(line, "@@"):
+			text = s.DiffHunk.Render(text)
+		case strings.HasPrefix(line, "+"):
+			text = s.DiffAdd.Render(text)
+		case strings.HasPrefix(line, "-"):
+			text = s.DiffRemove.Render(text)
+		default:
+			text = s.Dim.Render(text)
+		}
+		diffView.WriteString("\n" + text)
+	}
+
+	messageTitle := s.Title.Render("Commit message")
+	var message string
+	switch {
+	case m.gitGenerating:
+		message = s.Dim.Render("  Writing a commit message...")
+	case m.gitRunning == "commit":
+		message = s.Dim.Render("  Committing...")
+	case m.gitEditing || m.gitMessage.Value() != "":
+		message = m.gitMessage.View()
+	default:
+		message = s.Dim.Render("  none yet: g to generate one, e to write it")
+	}
+	if m.currentConversation != nil {
+		messageTitle += s.Dim.Render(fmt.Sprintf("  (adds %s: %s)", conversationTrailer, m.currentConversation.ID))
+	} else if len(m.chatMessages) > 0 {
+		messageTitle += s.Dim.Render(fmt.Sprintf("  (adds a %s trailer)", conversationTrailer))
+	}
+
+	footer := s.Footer("s", "stage", "c", "commit", "g", "generate message", "e", "edit message", "J/K", "scroll", "r", "refresh", "esc", "back")
+	if m.gitEditing {
+		footer = s.Footer("ctrl+s", "stage & commit", "esc", "done editing")
+	}
+	parts := []string{title, "", content.String(), "", diffView.String(), "", messageTitle, message}
+	if status := m.renderStatus(); status != "" {
+		parts = append(parts, "", status)
+	}
+	parts = append(parts, "", footer)
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
+}
+
 func (m model) viewConversationExport() string {
 	if m.selectedConv >= len(m.conversations) {
 		return s.Error.Render("No conversation selected")
@@ -347,7 +589,7 @@ func (m model) viewConversationExport() string {
 
 func (m model) viewSettings() string {
 	title := s.Title.Render("Settings")
-	labels := []string{"System Prompt:", "Your Name:", "Chat Timeout (seconds):"}
+	labels := []string{"System Prompt:", "Your Name:", "Chat Timeout (seconds):", "Context Overflow (summarize/truncate/off):"}
 	var fields []string
 	for i, input := range m.settingsInputs {
 		label := s.Success.Render(labels[i])
@@ -380,8 +622,27 @@ func (m model) viewModelManager() string {
 		content.WriteString("\n")
 	}
 
-	footer := s.Footer("j/k", "navigate", "enter", "set default", "n", "new", "e", "edit", "b", "browse Ollama library", "p", "pull Ollama model", "d", "delete", "esc", "back")
-	return lipgloss.JoinVertical(lipgloss.Left, title, "", content.String(), "", footer)
+	if len(m.profileModels) > 0 {
+		content.WriteString("\n")
+		content.WriteString(s.Dim.Render(fmt.Sprintf("Available on endpoint (%d):", len(m.profileModels))))
+		content.WriteString("\n")
+		for _, mdl := range m.profileModels {
+			line := "  " + mdl.Name
+			if mdl.Size > 0 {
+				line += s.Dim.Render(" (" + formatSize(mdl.Size) + ")")
+			}
+			content.WriteString(s.Normal.Render(line) + "\n")
+		}
+	}
+
+	footer := s.Footer("j/k", "navigate", "enter", "set default", "n", "new", "e", "edit", "l", "list endpoint models", "b", "browse Ollama library", "p", "pull Ollama model", "d", "delete", "esc", "back")
+	status := m.renderStatus()
+	parts := []string{title, "", content.String()}
+	if status != "" {
+		parts = append(parts, "", status)
+	}
+	parts = append(parts, "", footer)
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
 }
 
 func (m model) viewModelCreate() string {
@@ -391,7 +652,8 @@ func (m model) viewModelCreate() string {
 	}
 	title := s.Title.Render(titleText)
 
-	labels := []string{"Name:", "Provider:", "Model:", "System Prompt:", "Temperature (0-1):"}
+	labels := []string{"Name:", "Provider:", "Model:", "System Prompt:", "Temperature (0-1):", "Base URL:", "API Key Env Var:", "Extra Headers (Name: value; ...):",
+		"Options (num_ctx top_p top_k repeat_penalty seed stop num_predict keep_alive):"}
 	var fields []string
 	for i, input := range m.modelInputs {
 		label := s.Success.Render(labels[i])
@@ -443,7 +705,7 @@ func (m model) viewModelLibrary() string {
 
 		for i := scrollOff; i < end; i++ {
 			mdl := models[i]
-			installed := ollama.IsModelInstalled(mdl.Name, m.installedModels)
+			installed := provider.HasModel(mdl.Name, m.installedModels)
 			line := fmt.Sprintf("%-25s %s (%s)", mdl.Name, truncateStr(mdl.Description, 45), mdl.Size)
 
 			if i == m.librarySelection {
@@ -495,40 +757,21 @@ func (m model) viewReviewBar() string {
 	block := m.codeBlocks[idx]
 	var bar strings.Builder
 
-	// Show what file is being proposed
+	// File being proposed, with the size of the change
+	lines, summary := m.reviewDiffLines(block)
 	bar.WriteString(s.Title.Render(fmt.Sprintf("  %s", block.Path)))
 	if block.Language != "" {
 		bar.WriteString(s.Dim.Render(fmt.Sprintf(" (%s)", block.Language)))
 	}
+	bar.WriteString(s.Dim.Render("  " + summary))
+	if len(lines) > reviewDiffHeight {
+		scroll := min(m.reviewScroll, len(lines)-reviewDiffHeight)
+		bar.WriteString(s.Dim.Render(fmt.Sprintf("  [%d-%d of %d]", scroll+1, scroll+reviewDiffHeight, len(lines))))
+		lines = lines[scroll : scroll+reviewDiffHeight]
+	}
 	bar.WriteString("\n")
-
-	// Show diff if file exists, otherwise show preview
-	fullPath := filepath.Join(m.currentDir, block.Path)
-	if existing, err := os.ReadFile(fullPath); err == nil {
-		diffLines := generateDiff(string(existing), block.Content, block.Path)
-		maxDiff := 12
-		if len(diffLines) > maxDiff+2 { // skip header lines
-			diffLines = append(diffLines[:maxDiff+2], s.Dim.Render(fmt.Sprintf("  ... %d more lines", len(diffLines)-maxDiff-2)))
-		}
-		for _, line := range diffLines[2:] { // skip --- +++ headers
-			if strings.HasPrefix(line, "+ ") {
-				bar.WriteString(s.DiffAdd.Render(line) + "\n")
-			} else if strings.HasPrefix(line, "- ") {
-				bar.WriteString(s.DiffRemove.Render(line) + "\n")
-			} else {
-				bar.WriteString(s.Dim.Render(line) + "\n")
-			}
-		}
-	} else {
-		bar.WriteString(s.Success.Render("  [NEW FILE]") + "\n")
-		preview := strings.Split(block.Content, "\n")
-		maxPreview := 8
-		if len(preview) > maxPreview {
-			preview = append(preview[:maxPreview], s.Dim.Render(fmt.Sprintf("  ... %d more lines", len(preview)-maxPreview)))
-		}
-		for _, line := range preview {
-			bar.WriteString(s.DiffAdd.Render("+ "+line) + "\n")
-		}
+	for _, line := range append(targetWarnings(block.Target), lines...) {
+		bar.WriteString("  " + line + "\n")
 	}
 
 	// Count remaining
@@ -545,6 +788,19 @@ func (m model) viewReviewBar() string {
 	return bar.String()
 }
 
+// targetWarnings flags writes worth a second look: ones that create a
+// directory or land in a git-ignored file.
+func targetWarnings(t policy.Target) []string {
+	var lines []string
+	if t.NewDir != "" {
+		lines = append(lines, s.Warning.Render(fmt.Sprintf("⚠ creates new directory %s/", t.NewDir)))
+	}
+	if t.Ignored {
+		lines = append(lines, s.Warning.Render(fmt.Sprintf("⚠ %s is ignored by git", t.Rel)))
+	}
+	return lines
+}
+
 // =============================================================================
 // @ Autocomplete Overlay
 // =============================================================================
@@ -556,7 +812,7 @@ func (m model) overlayAtComplete(base string) string {
 	if filterDisplay == "" {
 		filterDisplay = ""
 	}
-	popup.WriteString(s.Title.Render("@ ") + s.Dim.Render(filterDisplay) + "\n")
+	popup.WriteString(s.Title.Render(m.atCompletePrefix+" ") + s.Dim.Render(filterDisplay) + "\n")
 
 	if len(m.atCompleteFiles) == 0 {
 		popup.WriteString(s.Dim.Render("  no matches\n"))
@@ -582,6 +838,9 @@ func (m model) overlayAtComplete(base string) string {
 
 		for i := start; i < end; i++ {
 			f := m.atCompleteFiles[i]
+			if cmd, ok := slashCommands[f]; ok && m.atCompletePrefix == "/" {
+				f = fmt.Sprintf("/%-8s %-10s %s", cmd.Name, cmd.Usage, s.Dim.Render(cmd.Help))
+			}
 			if i == m.atCompleteCursor {
 				popup.WriteString(s.Selected.Render("> "+f) + "\n")
 			} else {
@@ -656,6 +915,62 @@ func (m model) viewResourcePicker() string {
 	return lipgloss.JoinVertical(lipgloss.Left, title, "", content.String(), "", footer)
 }
 
+// =============================================================================
+// Template Picker
+// =============================================================================
+
+func (m model) viewTemplatePicker() string {
+	title := s.Title.Render("Prompt Templates")
+
+	if m.templateActive != nil {
+		t := m.templateActive
+		name := m.templateVars[len(m.templateValues)]
+		var content strings.Builder
+		content.WriteString(s.Dim.Render(fmt.Sprintf("%s — variable %d of %d", t.Name, len(m.templateValues)+1, len(m.templateVars))) + "\n\n")
+		content.WriteString(s.Normal.Render(fmt.Sprintf("{{%s}}:", name)) + "\n")
+		content.WriteString(m.templateInput.View())
+		footer := s.Footer("enter", "next", "esc", "back")
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", content.String(), "", footer)
+	}
+
+	var content strings.Builder
+	content.WriteString(s.Dim.Render(m.config.TemplatesDir) + "\n\n")
+	for i, t := range m.templateList {
+		line := t.Name
+		var pins []string
+		if t.Profile != "" {
+			pins = append(pins, "profile "+t.Profile)
+		}
+		if t.Temperature != nil {
+			pins = append(pins, fmt.Sprintf("temp %.2g", *t.Temperature))
+		}
+		if i == m.templateCursor {
+			content.WriteString(s.Selected.Render("> " + line))
+		} else {
+			content.WriteString(s.Normal.Render("  " + line))
+		}
+		if t.Description != "" {
+			content.WriteString(s.Dim.Render("  " + t.Description))
+		}
+		if len(pins) > 0 {
+			content.WriteString(s.Dim.Render("  [" + strings.Join(pins, ", ") + "]"))
+		}
+		content.WriteString("\n")
+	}
+
+	if m.templateCursor < len(m.templateList) {
+		preview := m.templateList[m.templateCursor].Body
+		lines := strings.Split(preview, "\n")
+		if len(lines) > 8 {
+			lines = append(lines[:8], "...")
+		}
+		content.WriteString("\n" + s.Dim.Render(strings.Join(lines, "\n")))
+	}
+
+	footer := s.Footer("j/k", "navigate", "enter", "insert", "esc", "cancel")
+	return lipgloss.JoinVertical(lipgloss.Left, title, "", content.String(), "", footer)
+}
+
 // =============================================================================
 // Confirm Dialog
 // =============================================================================
@@ -667,6 +982,9 @@ func (m model) viewConfirmDialog() string {
 	title := s.Warning.Render("Confirm")
 	content := s.Normal.Render(m.confirmDialog.Message)
 	footer := s.Footer("y", "yes", "n", "no", "esc", "cancel")
+	if _, ok := m.pendingCommand(); ok && m.confirmDialog.Action == ConfirmToolCall {
+		footer = s.Footer("y", "run once", "a", "always allow", "n", "decline")
+	}
 	return lipgloss.JoinVertical(lipgloss.Left, title, "", content, "", footer)
 }
 
@@ -735,10 +1053,19 @@ func (m model) renderHelp() string {
 		{"e", "Edit / export"},
 		{"d", "Delete"},
 		{"ctrl+r", "Attach local files as context"},
+		{"ctrl+t", "Insert a prompt template"},
 		{"@file", "Reference a project file in chat"},
+		{"/command", "Slash commands: /model /system /temp /save /export /attach /clear /tag /retry"},
 		{"alt+, / alt+.", "Switch model profile"},
 		{"ctrl+o", "Export current chat to Markdown"},
 		{"ctrl+y", "Copy one or more messages"},
+		{"ctrl+y, e", "Edit a sent message and resend as a branch"},
+		{"ctrl+y, h/l", "Flip between branches at a fork"},
+		{"alt+r", "Regenerate the last reply (keeps the old one)"},
+		{"alt+h / alt+l", "Previous / next version of the last reply"},
+		{"alt+u", "Undo the last file write (/changes lists them all)"},
+		{"/git", "Diff, stage and commit the files written this session"},
+		{"alt+f", "Send the output of failed post-apply hooks to the model"},
 		{"esc", "Back"},
 		{"q", "Quit"},
 		{"?", "Toggle this help"},